@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/notebook"
+	"github.com/horiagug/ruff-format-changes/internal/ruff"
+	"github.com/spf13/cobra"
+)
+
+// checkOptions holds the options specific to the check subcommand
+type checkOptions struct {
+	fix         bool
+	unsafeFixes bool
+}
+
+// newCheckCommand creates the check subcommand, which lints changed lines with ruff check
+func newCheckCommand(opts *options) *cobra.Command {
+	var checkOpts checkOptions
+
+	cmd := &cobra.Command{
+		Use:     "check",
+		Aliases: []string{"lint"},
+		Short:   "Lint only the changed lines in your Git branch using ruff check",
+		Long: `check runs 'ruff check' on the changed files and reports only the diagnostics
+located on lines that changed compared to the base branch or revision range.
+
+It exits with a non-zero status when any diagnostics remain. With --fix, only
+fixes whose edits lie entirely inside changed lines are applied.`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := applyConfig(*opts, cmd.Flags())
+			if err != nil {
+				return err
+			}
+			opts.configPath = cfg.Path
+			opts.similaritySet = cmd.Flags().Changed("similarity")
+			opts.baseSet = cmd.Flags().Changed("base")
+			return runCheck(*opts, checkOpts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&checkOpts.fix, "fix", false, "Apply fixes whose edits lie inside changed lines")
+	cmd.Flags().BoolVar(&checkOpts.unsafeFixes, "unsafe-fixes", false, "Also apply fixes ruff marks as unsafe (requires --fix)")
+
+	return cmd
+}
+
+// runCheck lints the changed lines and reports the remaining diagnostics on
+// stdout. Progress messages go to stderr with a machine readable output format.
+func runCheck(opts options, checkOpts checkOptions) error {
+	verbose, progress := opts.verbose, progressOutput(opts)
+
+	if checkOpts.unsafeFixes && !checkOpts.fix {
+		return fmt.Errorf("--unsafe-fixes requires --fix")
+	}
+
+	revRange, err := parseRevisionOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	if err := ruff.CheckRuffInstalled(); err != nil {
+		return err
+	}
+
+	if verbose && opts.configPath != "" {
+		fmt.Fprintf(progress, "Using configuration from %s\n", opts.configPath)
+	}
+
+	gitClient, err := newGitClient(opts)
+	if err != nil {
+		return err
+	}
+
+	// ruff check lints the files on disk, so a revision range must match them
+	fileChanges, err := collectFileChanges(gitClient, opts, revRange, false)
+	if err != nil {
+		return err
+	}
+	fileChanges = withoutNotebooks(fileChanges, verbose, progress)
+
+	if len(fileChanges) == 0 {
+		fmt.Fprintln(progress, "No Python files with changed lines in this branch")
+		return nil
+	}
+
+	ruffClient := ruff.New(gitClient.GetRepoRoot(), false, verbose)
+	ruffClient.SetLog(progress)
+
+	diagnostics, err := ruffClient.Check(fileChanges)
+	if err != nil {
+		return err
+	}
+	diagnostics = ruffClient.FilterDiagnostics(diagnostics, fileChanges)
+
+	if checkOpts.fix && len(diagnostics) > 0 {
+		applied, err := ruffClient.ApplyFixes(diagnostics, fileChanges, checkOpts.unsafeFixes)
+		if err != nil {
+			return err
+		}
+
+		if applied > 0 {
+			fmt.Fprintf(progress, "Fixed %d diagnostic(s) on changed lines\n", applied)
+
+			// Re-lint, since fixes may shift lines or resolve other diagnostics.
+			// The changed lines are collected again, as fixes that add or remove
+			// lines move the ranges that follow them.
+			fileChanges, err = collectFileChanges(gitClient, opts, revRange, false)
+			if err != nil {
+				return err
+			}
+			fileChanges = withoutNotebooks(fileChanges, false, progress)
+
+			diagnostics, err = ruffClient.Check(fileChanges)
+			if err != nil {
+				return err
+			}
+			diagnostics = ruffClient.FilterDiagnostics(diagnostics, fileChanges)
+		}
+	}
+
+	if len(diagnostics) == 0 {
+		fmt.Fprintln(progress, "No diagnostics on changed lines")
+		return nil
+	}
+
+	for _, d := range diagnostics {
+		fmt.Println(formatDiagnostic(gitClient.GetRepoRoot(), d))
+	}
+
+	return fmt.Errorf("found %d diagnostic(s) on changed lines", len(diagnostics))
+}
+
+// withoutNotebooks drops notebooks from the changed files. Changed lines of
+// notebooks refer to the notebook JSON, while ruff reports notebook diagnostics
+// by cell, so notebooks are not linted.
+func withoutNotebooks(fileChanges []git.FileChanges, verbose bool, progress io.Writer) []git.FileChanges {
+	var sources []git.FileChanges
+	for _, fc := range fileChanges {
+		if notebook.IsNotebook(fc.FilePath) {
+			if verbose {
+				fmt.Fprintf(progress, "Skipping notebook %s\n", fc.FilePath)
+			}
+			continue
+		}
+		sources = append(sources, fc)
+	}
+	return sources
+}
+
+// formatDiagnostic renders a diagnostic as "path:row:col: CODE message"
+func formatDiagnostic(repoRoot string, d ruff.Diagnostic) string {
+	path := relativePath(repoRoot, d.Filename)
+
+	code := d.Code
+	if code == "" {
+		code = "error"
+	}
+
+	line := fmt.Sprintf("%s:%d:%d: %s %s", path, d.Location.Row, d.Location.Column, code, d.Message)
+	if d.Fix != nil && d.Fix.Applicability == "safe" {
+		line += " [*]"
+	}
+	return line
+}
+
+// relativePath returns path relative to the repository root when it is inside it
+func relativePath(repoRoot, path string) string {
+	rel, err := filepath.Rel(repoRoot, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
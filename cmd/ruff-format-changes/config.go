@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/horiagug/ruff-format-changes/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// newConfigCommand creates the config subcommand, which inspects the configuration
+func newConfigCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration and where each setting comes from",
+		Long: `show prints the value of every setting after applying, in order of precedence,
+command line flags, RUFF_FORMAT_CHANGES_* environment variables, the project
+configuration file (.ruff-format-changes.toml or [tool.ruff-format-changes] in
+pyproject.toml) and the defaults.`,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// The root command's own flags are never set here, but show their defaults
+			cfg, entries, err := applyConfig(*opts, cmd.Flags(), cmd.Root().Flags())
+			if err != nil {
+				return err
+			}
+			return printConfig(cmd.OutOrStdout(), cfg, entries)
+		},
+	})
+
+	return cmd
+}
+
+// applyConfig loads the configuration of the repository selected by --repo and
+// applies it to the settings whose flags were not given on the command line.
+// Outside a repository only environment variables apply.
+func applyConfig(opts options, flagSets ...*pflag.FlagSet) (*config.Config, []config.Entry, error) {
+	repoRoot := ""
+	if output, err := gitCommand(opts.repoDir, "rev-parse", "--show-toplevel").Output(); err == nil {
+		repoRoot = strings.TrimSpace(string(output))
+	}
+
+	cfg := &config.Config{}
+	if repoRoot != "" {
+		loaded, err := config.Load(repoRoot)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg = loaded
+	}
+
+	entries, err := cfg.Apply(os.Getenv, flagSets...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, entries, nil
+}
+
+// printConfig prints the effective configuration as TOML, with the source of
+// each setting as a comment
+func printConfig(w io.Writer, cfg *config.Config, entries []config.Entry) error {
+	if cfg.Path != "" {
+		fmt.Fprintf(w, "# Configuration file: %s\n", cfg.Path)
+	} else {
+		fmt.Fprintln(w, "# No configuration file found")
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		fmt.Fprintf(tw, "%s = %s\t# %s\n", entry.Key, formatConfigValue(entry.Value), entry.Source)
+	}
+	return tw.Flush()
+}
+
+// formatConfigValue formats a setting as a TOML value
+func formatConfigValue(value any) string {
+	switch v := value.(type) {
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	case int:
+		return strconv.Itoa(v)
+	default:
+		return strconv.Quote(fmt.Sprint(v))
+	}
+}
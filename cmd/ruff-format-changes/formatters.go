@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/ruff"
+)
+
+// formatterNames lists the backends accepted by --formatter
+var formatterNames = []string{"ruff", "black", "yapf", "isort"}
+
+// newFormatter creates the formatter backend selected with --formatter
+func newFormatter(name, repoRoot string, dryRun, verbose bool) (formatter.Formatter, error) {
+	switch name {
+	case "ruff":
+		return ruff.New(repoRoot, dryRun, verbose), nil
+	case "black":
+		return formatter.NewBlack(repoRoot, dryRun, verbose), nil
+	case "yapf":
+		return formatter.NewYapf(repoRoot, dryRun, verbose), nil
+	case "isort":
+		return formatter.NewIsort(repoRoot, dryRun, verbose), nil
+	default:
+		return nil, fmt.Errorf("unknown formatter %q (available: %s)", name, strings.Join(formatterNames, ", "))
+	}
+}
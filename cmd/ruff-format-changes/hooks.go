@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/hooks"
+	"github.com/spf13/cobra"
+)
+
+// newHooksCommand creates the hooks subcommand, which manages git hooks that
+// run ruff-format-changes
+func newHooksCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hooks",
+		Short: "Install, remove or inspect git hooks that run ruff-format-changes",
+		Long: `hooks manages git hooks in .git/hooks, or in core.hooksPath when it is set.
+
+The pre-commit hook formats the staged lines with --staged and fails the commit
+when formatting fails. The optional pre-push hook runs --check on the lines
+changed by the pushed commits. A hook that already exists is kept with the .local
+suffix and run first.`,
+	}
+
+	var prePush bool
+	install := &cobra.Command{
+		Use:           "install",
+		Short:         "Install the pre-commit hook, and the pre-push hook with --pre-push",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := []string{hooks.PreCommit}
+			if prePush {
+				names = append(names, hooks.PrePush)
+			}
+			return runHooksInstall(*opts, names)
+		},
+	}
+	install.Flags().BoolVar(&prePush, "pre-push", false, "Also install a pre-push hook that checks the pushed changes")
+
+	uninstall := &cobra.Command{
+		Use:           "uninstall",
+		Short:         "Remove the installed hooks and restore the hooks they chained",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHooksUninstall(*opts)
+		},
+	}
+
+	status := &cobra.Command{
+		Use:           "status",
+		Short:         "Show which hooks are installed",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHooksStatus(*opts)
+		},
+	}
+
+	cmd.AddCommand(install, uninstall, status)
+	return cmd
+}
+
+// hooksDir returns the hooks directory of the repository selected by --repo
+func hooksDir(opts options) (string, error) {
+	gitClient, err := git.NewAt(opts.repoDir, opts.verbose)
+	if err != nil {
+		return "", err
+	}
+	return gitClient.HooksDir()
+}
+
+// runHooksInstall installs the named hooks
+func runHooksInstall(opts options, names []string) error {
+	dir, err := hooksDir(opts)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		chained, err := hooks.Install(dir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Installed %s hook in %s\n", name, dir)
+		if chained {
+			fmt.Printf("The existing %s hook was kept as %s.local and runs first\n", name, name)
+		}
+	}
+	return nil
+}
+
+// runHooksUninstall removes every managed hook
+func runHooksUninstall(opts options) error {
+	dir, err := hooksDir(opts)
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, name := range hooks.Names {
+		status, err := hooks.GetStatus(dir, name)
+		if err != nil {
+			return err
+		}
+		ok, err := hooks.Uninstall(dir, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		removed++
+		fmt.Printf("Removed %s hook\n", name)
+		if status.Chained {
+			fmt.Printf("Restored the previous %s hook\n", name)
+		}
+	}
+
+	if removed == 0 {
+		fmt.Println("No hooks installed by ruff-format-changes")
+	}
+	return nil
+}
+
+// runHooksStatus prints the state of every hook
+func runHooksStatus(opts options) error {
+	dir, err := hooksDir(opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Hooks directory: %s\n", dir)
+	for _, name := range hooks.Names {
+		status, err := hooks.GetStatus(dir, name)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case status.Installed && status.Chained:
+			fmt.Printf("%s: installed, running %s.local first\n", name, name)
+		case status.Installed:
+			fmt.Printf("%s: installed\n", name)
+		case status.Foreign:
+			fmt.Printf("%s: not installed (another hook is present and would be chained)\n", name)
+		default:
+			fmt.Printf("%s: not installed\n", name)
+		}
+	}
+	return nil
+}
@@ -1,22 +1,93 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"runtime"
 	"strings"
 
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
 	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/notebook"
+	"github.com/horiagug/ruff-format-changes/internal/output"
 	"github.com/horiagug/ruff-format-changes/internal/ruff"
+	"github.com/horiagug/ruff-format-changes/internal/selection"
 	"github.com/spf13/cobra"
 )
 
+// options holds the command line options for a format run
+type options struct {
+	repoDir          string
+	baseBranch       string
+	dryRun           bool
+	verbose          bool
+	staged           bool
+	fromRev          string
+	toRev            string
+	revRange         string
+	noMergeBase      bool
+	formatter        string
+	outputFmt        string
+	check            bool
+	patch            string
+	jobs             int
+	stdin            bool
+	noRollback       bool
+	extensions       []string
+	include          []string
+	exclude          []string
+	noShebang        bool
+	noRenames        bool
+	findCopies       bool
+	similarity       int
+	ignoreWhitespace bool
+	ignoreBlankLines bool
+	ignoreComments   bool
+	seams            string
+	expand           string
+	mergeGap         int
+	// similaritySet is true when --similarity was given, so that 0 is not
+	// mistaken for an unset similarity
+	similaritySet bool
+	// baseSet is true when --base was given, since a base from the
+	// configuration only applies when no revision range is
+	baseSet bool
+	// configPath is the configuration file applied to the options, if any
+	configPath string
+	// strictRanges is "revert" or "report" to check for edits outside the changed ranges
+	strictRanges string
+}
+
+// Exit codes of a --check run
+const (
+	exitClean           = 0
+	exitNeedsFormatting = 1
+	exitToolError       = 2
+)
+
+// exitCodeError makes the process exit with a specific code. A nil err exits
+// silently, e.g. when --check finds lines that need formatting.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
 func main() {
-	var (
-		baseBranch string
-		dryRun     bool
-		verbose    bool
-	)
+	var opts options
 
 	rootCmd := &cobra.Command{
 		Use:   "ruff-format-changes",
@@ -24,93 +95,533 @@ func main() {
 		Long: `ruff-format-changes is a utility that runs 'ruff format' only on the lines
 that have changed in your current Git branch compared to a base branch (usually main or master).
 
-This helps keep your code formatted without reformatting the entire codebase.`,
+This helps keep your code formatted without reformatting the entire codebase.
+
+With --check, nothing is modified and the exit code reports the result:
+  0  all changed lines are formatted
+  1  some changed lines need formatting
+  2  the formatter, git or the tool itself failed`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runCommand(baseBranch, dryRun, verbose)
+			cfg, _, err := applyConfig(opts, cmd.Flags())
+			if err != nil {
+				if opts.check {
+					return &exitCodeError{code: exitToolError, err: err}
+				}
+				return err
+			}
+			opts.configPath = cfg.Path
+			opts.similaritySet = cmd.Flags().Changed("similarity")
+			opts.baseSet = cmd.Flags().Changed("base")
+
+			if !opts.check {
+				_, err := runCommand(opts)
+				return err
+			}
+
+			opts.dryRun = true
+			code, err := runCheckMode(opts)
+			if err != nil {
+				return &exitCodeError{code: exitToolError, err: err}
+			}
+			if code != exitClean {
+				return &exitCodeError{code: code}
+			}
+			return nil
 		},
 	}
 
-	rootCmd.Flags().StringVar(&baseBranch, "base", "", "Base branch to compare against (default: main or master)")
-	rootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Preview changes without modifying files")
-	rootCmd.Flags().BoolVar(&verbose, "verbose", false, "Show detailed output")
+	rootCmd.PersistentFlags().StringVarP(&opts.repoDir, "repo", "C", "", "Run as if started in this directory (default: current directory)")
+	rootCmd.PersistentFlags().StringVar(&opts.baseBranch, "base", "", "Base branch to compare against (default: main or master)")
+	rootCmd.PersistentFlags().BoolVar(&opts.verbose, "verbose", false, "Show detailed output")
+	rootCmd.PersistentFlags().StringVar(&opts.fromRev, "from", "", "Start of the revision range to compute changes for")
+	rootCmd.PersistentFlags().StringVar(&opts.toRev, "to", "", "End of the revision range (default: working tree)")
+	rootCmd.PersistentFlags().BoolVar(&opts.noMergeBase, "no-merge-base", false, "Diff against the tip of the base branch instead of its merge base with HEAD")
+	rootCmd.PersistentFlags().StringVar(&opts.revRange, "range", "", "Revision range to compute changes for (e.g. origin/main...HEAD, HEAD~1..HEAD)")
+	rootCmd.PersistentFlags().StringSliceVar(&opts.extensions, "extensions", selection.DefaultExtensions, "Extensions of the files to format")
+	rootCmd.PersistentFlags().StringArrayVar(&opts.include, "include", nil, "Only format files matching this glob (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&opts.exclude, "exclude", nil, "Skip files matching this glob (repeatable), in addition to ruff's exclude and extend-exclude")
+	rootCmd.PersistentFlags().BoolVar(&opts.noShebang, "no-shebang", false, "Don't format files without an extension that start with a python shebang")
+	rootCmd.PersistentFlags().BoolVar(&opts.noRenames, "no-renames", false, "Treat renamed files as new instead of diffing them against their old path")
+	rootCmd.PersistentFlags().BoolVar(&opts.findCopies, "find-copies", false, "Also diff copied files against the file they were copied from")
+	rootCmd.PersistentFlags().IntVar(&opts.similarity, "similarity", git.DefaultDiffOptions().Similarity, "Percentage of a file that must be unchanged to count as renamed or copied")
+	rootCmd.PersistentFlags().BoolVarP(&opts.ignoreWhitespace, "ignore-whitespace", "w", false, "Ignore whitespace-only changes, such as re-indented lines")
+	rootCmd.PersistentFlags().BoolVar(&opts.ignoreBlankLines, "ignore-blank-lines", false, "Ignore changes that only add or remove blank lines")
+	rootCmd.PersistentFlags().BoolVar(&opts.ignoreComments, "ignore-comments", false, "Skip changed lines that are only comments or blank")
+	rootCmd.PersistentFlags().StringVar(&opts.seams, "seams", string(git.SeamOff), "Lines to format where lines were only deleted: off, line (the lines around the deletion) or statement (the statements around it)")
+	rootCmd.PersistentFlags().StringVar(&opts.expand, "expand", "", "Widen changed lines to the enclosing Python statement, function or class")
+	rootCmd.PersistentFlags().IntVar(&opts.mergeGap, "merge-gap", 0, "Merge changed ranges separated by at most this many unchanged lines, so they are formatted in one run")
+	rootCmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Preview changes without modifying files")
+	rootCmd.Flags().BoolVar(&opts.check, "check", false, "Dry run that exits 1 if changed lines need formatting and 2 on errors")
+	rootCmd.Flags().StringVar(&opts.formatter, "formatter", "ruff", "Formatter backend to use: "+strings.Join(formatterNames, ", "))
+	rootCmd.Flags().StringVar(&opts.outputFmt, "output-format", "text", "Output format: "+strings.Join(output.Formats, ", "))
+	rootCmd.Flags().StringVar(&opts.patch, "patch", "", "Write a single unified diff of the formatting to a file (or - for stdout) instead of modifying files")
+	rootCmd.Flags().IntVarP(&opts.jobs, "jobs", "j", runtime.NumCPU(), "Number of files to format in parallel")
+	rootCmd.Flags().BoolVar(&opts.stdin, "stdin", false, "Pipe each file through ruff in memory and write it once, instead of rewriting it per range")
+	rootCmd.Flags().BoolVar(&opts.noRollback, "no-rollback", false, "Keep partially formatted files instead of restoring them when the formatter fails")
+	rootCmd.Flags().StringVar(&opts.strictRanges, "strict-ranges", "", "Revert (or with =report, only report) formatting edits outside the changed lines")
+	rootCmd.Flags().Lookup("strict-ranges").NoOptDefVal = strictRevert
+	rootCmd.Flags().BoolVar(&opts.staged, "staged", false, "Format the staged content of changed lines and re-stage it")
+
+	rootCmd.AddCommand(newCheckCommand(&opts))
+	rootCmd.AddCommand(newConfigCommand(&opts))
+	rootCmd.AddCommand(newHooksCommand(&opts))
 
 	if err := rootCmd.Execute(); err != nil {
+		code := 1
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+			if exitErr.err == nil {
+				os.Exit(code)
+			}
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(code)
 	}
 }
 
-func runCommand(baseBranch string, dryRun, verbose bool) error {
-	if err := ruff.CheckRuffInstalled(); err != nil {
-		return err
+// runCheckMode runs a dry run and returns exitNeedsFormatting if any changed
+// lines would be reformatted. Errors are returned as is; the caller maps them
+// to exitToolError.
+func runCheckMode(opts options) (int, error) {
+	needsFormatting, err := runCommand(opts)
+	if err != nil {
+		return exitToolError, err
+	}
+	if needsFormatting {
+		return exitNeedsFormatting, nil
+	}
+	return exitClean, nil
+}
+
+// runCommand formats the changed lines, or previews them in dry-run mode. It
+// reports whether any changed lines would be reformatted.
+func runCommand(opts options) (bool, error) {
+	dryRun, verbose := opts.dryRun, opts.verbose
+
+	revRange, err := parseRevisionOptions(opts)
+	if err != nil {
+		return false, err
+	}
+
+	if err := output.ValidateFormat(opts.outputFmt); err != nil {
+		return false, err
+	}
+
+	if err := validateStrictRanges(opts); err != nil {
+		return false, err
+	}
+
+	if opts.jobs < 0 {
+		return false, fmt.Errorf("--jobs must not be negative, got %d", opts.jobs)
+	}
+
+	if opts.patch != "" {
+		if opts.staged {
+			return false, fmt.Errorf("--patch is not supported with --staged")
+		}
+		if opts.patch == "-" && opts.outputFmt != "text" {
+			return false, fmt.Errorf("--patch - cannot be combined with --output-format %s", opts.outputFmt)
+		}
+		// A patch never modifies files
+		dryRun = true
 	}
 
+	if opts.staged && opts.outputFmt != "text" {
+		return false, fmt.Errorf("--output-format %s is not supported with --staged", opts.outputFmt)
+	}
+
+	reportOut := os.Stdout
+	progress := progressOutput(opts)
+
 	if verbose {
-		fmt.Println("Initializing Git repository...")
+		if opts.configPath != "" {
+			fmt.Fprintf(progress, "Using configuration from %s\n", opts.configPath)
+		}
+		fmt.Fprintln(progress, "Initializing Git repository...")
 	}
 
-	gitClient, err := git.New(verbose)
+	gitClient, err := newGitClient(opts)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	fmtr, err := newFormatter(opts.formatter, gitClient.GetRepoRoot(), dryRun, verbose)
+	if err != nil {
+		return false, err
+	}
+
+	if opts.stdin {
+		ruffClient, ok := fmtr.(*ruff.Ruff)
+		if !ok {
+			return false, fmt.Errorf("--stdin is only supported by the ruff formatter")
+		}
+		ruffClient.SetStdin(true)
+	}
+
+	if err := fmtr.CheckInstalled(); err != nil {
+		return false, err
+	}
+
+	fmtr = notebook.NewFormatter(fmtr, gitClient.GetRepoRoot(), dryRun, verbose)
+	fmtr.SetLog(progress)
+
+	// Zero keeps the formatter's default of one job per CPU
+	if opts.jobs > 0 {
+		fmtr.SetJobs(opts.jobs)
 	}
 
 	currentBranch, err := gitClient.GetCurrentBranch()
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if verbose {
+		fmt.Fprintf(progress, "Current branch: %s\n", currentBranch)
+	}
+
+	if opts.staged {
+		wouldReformat, err := runStaged(gitClient, fmtr, dryRun, verbose, progress)
+		return wouldReformat > 0, err
+	}
+
+	fileChanges, err := collectFileChanges(gitClient, opts, revRange, dryRun)
+	if err != nil {
+		return false, err
+	}
+
+	if len(fileChanges) == 0 {
+		fmt.Fprintln(progress, "No Python files with changed lines in this branch")
+		if opts.patch != "" {
+			return false, writePatch(opts.patch, "", reportOut)
+		}
+		if opts.outputFmt != "text" {
+			return false, output.Write(reportOut, opts.outputFmt, &formatter.Report{Formatter: fmtr.Name(), DryRun: dryRun}, verbose)
+		}
+		return false, nil
 	}
 
 	if verbose {
-		fmt.Printf("Current branch: %s\n", currentBranch)
+		fmt.Fprintln(progress)
+	}
+
+	// The line ranges of a revision range refer to the files at its end, which a
+	// read-only run formats in memory instead of the files on disk
+	read := readWorkingTree(gitClient.GetRepoRoot())
+	inMemory := dryRun && revRange != nil && revRange.To != ""
+	if inMemory {
+		read = func(path string) ([]byte, error) {
+			return gitClient.ReadRevisionFile(revRange.To, path)
+		}
+	}
+
+	if opts.patch != "" {
+		patch, changed, err := buildPatch(fmtr, fileChanges, read, opts.strictRanges, progress)
+		if err != nil {
+			return false, err
+		}
+		if err := writePatch(opts.patch, patch, reportOut); err != nil {
+			return false, err
+		}
+		if opts.patch != "-" {
+			fmt.Fprintf(progress, "Wrote patch for %d file(s) to %s\n", changed, opts.patch)
+		} else if verbose {
+			fmt.Fprintf(progress, "Wrote patch for %d file(s)\n", changed)
+		}
+		return changed > 0, nil
+	}
+
+	if dryRun {
+		fmt.Fprintf(progress, "Running %s in dry-run mode...\n", fmtr.Name())
+		fmt.Fprintln(progress)
+	} else {
+		fmt.Fprintf(progress, "Running %s on changed lines...\n", fmtr.Name())
+		fmt.Fprintln(progress)
+	}
+
+	// Snapshot the files so a failure doesn't leave them partially formatted and
+	// formatted files can be compared with their original content
+	var snapshot *formatter.Snapshot
+	if !dryRun && (!opts.noRollback || opts.strictRanges != "") {
+		snapshot, err = formatter.TakeSnapshot(gitClient.GetRepoRoot(), fileChanges)
+		if err != nil {
+			return false, err
+		}
 	}
 
+	var report *formatter.Report
+	if inMemory {
+		jobs := opts.jobs
+		if jobs == 0 {
+			jobs = runtime.NumCPU()
+		}
+		report, err = formatInMemory(fmtr, fileChanges, read, jobs, progress)
+	} else {
+		report, err = fmtr.FormatFilesByLineRanges(fileChanges)
+	}
+	if snapshot != nil && err != nil && !opts.noRollback {
+		if rollbackErr := rollback(snapshot, report, progress); rollbackErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", rollbackErr)
+		}
+	}
+	if report == nil {
+		return false, err
+	}
+
+	if snapshot != nil && opts.strictRanges != "" {
+		if strictErr := enforceStrictRanges(gitClient.GetRepoRoot(), snapshot, fileChanges, report, opts.strictRanges); strictErr != nil && err == nil {
+			err = strictErr
+		}
+	}
+
+	if writeErr := output.Write(reportOut, opts.outputFmt, report, verbose); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return report.NeedsFormatting(), err
+}
+
+// rollback restores every file in the snapshot that the formatter changed and
+// records the restored files in the report
+func rollback(snapshot *formatter.Snapshot, report *formatter.Report, progress io.Writer) error {
+	restored, err := snapshot.Restore()
+	if report != nil {
+		report.MarkRolledBack(restored)
+	}
+	if len(restored) > 0 {
+		fmt.Fprintf(progress, "Formatting failed; rolled back %d file(s) to their original content\n", len(restored))
+	}
+	return err
+}
+
+// newGitClient opens the repository selected by --repo, restricts the changed
+// files it reports to those selected by the file selection options and sets up
+// rename and copy detection, the changes to ignore, the seam policy, the
+// expansion of changed lines and the gap within which ranges are merged
+func newGitClient(opts options) (*git.Git, error) {
+	gitClient, err := git.NewAt(opts.repoDir, opts.verbose)
+	if err != nil {
+		return nil, err
+	}
+	gitClient.SetLog(progressOutput(opts))
+
+	selector, err := selection.New(gitClient.GetRepoRoot(), selection.Options{
+		Extensions: opts.extensions,
+		Include:    opts.include,
+		Exclude:    opts.exclude,
+		NoShebang:  opts.noShebang,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.verbose && len(selector.RuffExcludes()) > 0 {
+		fmt.Fprintf(progressOutput(opts), "Excluding files from ruff configuration: %s\n", strings.Join(selector.RuffExcludes(), ", "))
+	}
+
+	gitClient.SetFileFilter(selector.Match)
+
+	diffOptions := git.DefaultDiffOptions()
+	diffOptions.Renames = !opts.noRenames
+	diffOptions.Copies = opts.findCopies
+	diffOptions.IgnoreWhitespace = opts.ignoreWhitespace
+	diffOptions.IgnoreBlankLines = opts.ignoreBlankLines
+	diffOptions.IgnoreComments = opts.ignoreComments
+	// An unset similarity keeps git's default
+	if opts.similarity != 0 || opts.similaritySet {
+		diffOptions.Similarity = opts.similarity
+	}
+	if err := gitClient.SetDiffOptions(diffOptions); err != nil {
+		return nil, fmt.Errorf("invalid --similarity: %w", err)
+	}
+
+	// Empty keeps the default policy
+	if opts.seams != "" {
+		policy, err := git.ParseSeamPolicy(opts.seams)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --seams: %w", err)
+		}
+		gitClient.SetSeamPolicy(policy)
+	}
+
+	expansion, err := git.ParseExpansion(opts.expand)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --expand: %w", err)
+	}
+	gitClient.SetExpansion(expansion)
+
+	if err := gitClient.SetMergeGap(opts.mergeGap); err != nil {
+		return nil, fmt.Errorf("invalid --merge-gap: %w", err)
+	}
+
+	return gitClient, nil
+}
+
+// collectFileChanges returns the changed line ranges selected by the base branch
+// or revision range options. The line ranges of a revision range refer to the
+// files at its end, so unless the caller is readOnly and reads the files there
+// itself, the range must end at HEAD and the changed files must match HEAD.
+func collectFileChanges(gitClient *git.Git, opts options, revRange *git.RevisionRange, readOnly bool) ([]git.FileChanges, error) {
+	verbose, progress := opts.verbose, progressOutput(opts)
+
+	if revRange != nil {
+		if verbose {
+			fmt.Fprintf(progress, "Comparing revision range: %s\n", revRange)
+			fmt.Fprintln(progress, "Getting changed lines...")
+		}
+
+		fileChanges, err := gitClient.GetChangedLineRangesInRange(*revRange)
+		if err != nil {
+			return nil, err
+		}
+
+		if !readOnly {
+			if err := checkRangeMatchesWorkingTree(gitClient, *revRange, fileChanges); err != nil {
+				return nil, err
+			}
+		}
+		return fileChanges, nil
+	}
+
+	baseBranch := opts.baseBranch
 	if baseBranch == "" {
-		baseBranch = determineBaseBranch(gitClient)
+		baseBranch = determineBaseBranch(gitClient.GetRepoRoot())
 		if verbose {
-			fmt.Printf("Using base branch: %s\n", baseBranch)
+			fmt.Fprintf(progress, "Using base branch: %s\n", baseBranch)
 		}
 	}
 
 	if verbose {
-		fmt.Printf("Comparing against branch: %s\n", baseBranch)
-		fmt.Println("Getting changed lines...")
+		fmt.Fprintf(progress, "Comparing against branch: %s\n", baseBranch)
+	}
+
+	diffBase := baseBranch
+	if !opts.noMergeBase {
+		mergeBase, err := resolveMergeBase(gitClient, baseBranch, opts.check, verbose, progress)
+		if err != nil {
+			return nil, err
+		}
+		diffBase = mergeBase
 	}
 
-	fileChanges, err := gitClient.GetChangedLineRanges(baseBranch)
+	if verbose {
+		fmt.Fprintln(progress, "Getting changed lines...")
+	}
+
+	return gitClient.GetChangedLineRanges(diffBase)
+}
+
+// resolveMergeBase returns the merge base of baseBranch and HEAD, so that changes
+// made on the base branch after this branch forked are not reported. It warns and
+// falls back to baseBranch itself when no merge base can be found, unless strict
+// is set, since a --check run would then judge lines this branch never changed.
+func resolveMergeBase(gitClient *git.Git, baseBranch string, strict, verbose bool, progress io.Writer) (string, error) {
+	mergeBase, err := gitClient.MergeBase(baseBranch, "HEAD")
 	if err != nil {
-		return err
+		if strict {
+			return "", fmt.Errorf("%w; use --no-merge-base to diff against %s", err, baseBranch)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v; diffing against %s instead\n", err, baseBranch)
+		return baseBranch, nil
 	}
 
-	if len(fileChanges) == 0 {
-		fmt.Println("No Python files with changed lines in this branch")
+	if verbose {
+		fmt.Fprintf(progress, "Using merge base: %s\n", mergeBase)
+	}
+	return mergeBase, nil
+}
+
+// progressOutput returns where progress messages are written. Machine readable
+// output owns stdout, so they go to stderr with it.
+func progressOutput(opts options) io.Writer {
+	if opts.outputFmt != "text" || opts.patch == "-" {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// parseRevisionOptions builds the revision range requested with --range or
+// --from/--to. It returns nil when the default base branch comparison applies.
+func parseRevisionOptions(opts options) (*git.RevisionRange, error) {
+	if opts.revRange != "" && (opts.fromRev != "" || opts.toRev != "") {
+		return nil, fmt.Errorf("--range cannot be combined with --from or --to")
+	}
+	if opts.toRev != "" && opts.fromRev == "" {
+		return nil, fmt.Errorf("--to requires --from")
+	}
+
+	var rr git.RevisionRange
+	switch {
+	case opts.revRange != "":
+		parsed, err := git.ParseRevisionRange(opts.revRange)
+		if err != nil {
+			return nil, err
+		}
+		rr = parsed
+	case opts.fromRev != "":
+		rr = git.RevisionRange{From: opts.fromRev, To: opts.toRev}
+	default:
+		return nil, nil
+	}
+
+	// A configured base is for runs without a revision range, so only the flag conflicts
+	if opts.baseSet {
+		return nil, fmt.Errorf("--base cannot be combined with a revision range")
+	}
+	if opts.staged {
+		return nil, fmt.Errorf("--staged cannot be combined with a revision range")
+	}
+
+	return &rr, nil
+}
+
+// checkRangeMatchesWorkingTree refuses to use the files on disk when the range
+// ends at a commit other than HEAD or the changed files differ from HEAD, since
+// the line numbers would not match them
+func checkRangeMatchesWorkingTree(gitClient *git.Git, rr git.RevisionRange, fileChanges []git.FileChanges) error {
+	if rr.To == "" {
 		return nil
 	}
 
-	if verbose {
-		fmt.Println()
+	to, err := gitClient.ResolveRevision(rr.To)
+	if err != nil {
+		return err
+	}
+	head, err := gitClient.ResolveRevision("HEAD")
+	if err != nil {
+		return err
 	}
 
-	ruffClient := ruff.New(gitClient.GetRepoRoot(), dryRun, verbose)
+	if to != head {
+		return fmt.Errorf("revision range %s does not end at HEAD; use --dry-run or check out %s first", rr, rr.To)
+	}
 
-	if dryRun {
-		fmt.Println("Running ruff format in dry-run mode (--check --diff)...")
-		fmt.Println()
-	} else {
-		fmt.Println("Running ruff format on changed lines...")
-		fmt.Println()
+	paths := make([]string, len(fileChanges))
+	for i, fc := range fileChanges {
+		paths[i] = fc.FilePath
+	}
+	dirty, err := gitClient.HasUncommittedChanges(paths)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("files changed in %s have uncommitted changes; use --dry-run or commit or stash them first", rr)
 	}
 
-	return ruffClient.FormatFilesByLineRanges(fileChanges)
+	return nil
 }
 
-func determineBaseBranch(gitClient *git.Git) string {
-	parentBranch := findParentBranch()
+// determineBaseBranch guesses the branch the current branch of the repository
+// at repoRoot was created from. An empty repoRoot means the current directory.
+func determineBaseBranch(repoRoot string) string {
+	parentBranch := findParentBranch(repoRoot)
 	if parentBranch != "" {
 		return parentBranch
 	}
 
-	currentBranch, err := gitClient.GetCurrentBranch()
-	if err != nil {
-		currentBranch = ""
+	currentBranch := ""
+	if output, err := gitCommand(repoRoot, "rev-parse", "--abbrev-ref", "HEAD").Output(); err == nil {
+		currentBranch = strings.TrimSpace(string(output))
 	}
 
 	commonBranches := []string{"main", "master", "develop", "development"}
@@ -119,13 +630,13 @@ func determineBaseBranch(gitClient *git.Git) string {
 		if branch == currentBranch {
 			continue
 		}
-		if branchExists(branch) {
+		if branchExists(repoRoot, branch) {
 			return branch
 		}
 	}
 
-	defaultBranch := getRemoteDefaultBranch()
-	if defaultBranch != "" && defaultBranch != currentBranch && branchExists(defaultBranch) {
+	defaultBranch := getRemoteDefaultBranch(repoRoot)
+	if defaultBranch != "" && defaultBranch != currentBranch && branchExists(repoRoot, defaultBranch) {
 		return defaultBranch
 	}
 
@@ -134,14 +645,14 @@ func determineBaseBranch(gitClient *git.Git) string {
 
 // findParentBranch finds the parent branch of the current branch using git show-branch
 // by parsing the output to find the nearest ancestor branch.
-func findParentBranch() string {
-	cmd := exec.Command("git", "show-branch")
+func findParentBranch(repoRoot string) string {
+	cmd := gitCommand(repoRoot, "show-branch")
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
 	}
 
-	currentBranch, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	currentBranch, err := gitCommand(repoRoot, "rev-parse", "--abbrev-ref", "HEAD").Output()
 	if err != nil {
 		return ""
 	}
@@ -245,15 +756,15 @@ func extractBranchName(line string) string {
 }
 
 // branchExists checks if a branch exists locally
-func branchExists(branch string) bool {
-	cmd := exec.Command("git", "rev-parse", "--verify", branch)
+func branchExists(repoRoot, branch string) bool {
+	cmd := gitCommand(repoRoot, "rev-parse", "--verify", branch)
 	err := cmd.Run()
 	return err == nil
 }
 
 // getRemoteDefaultBranch gets the default branch from the remote origin.
-func getRemoteDefaultBranch() string {
-	cmd := exec.Command("git", "symbolic-ref", "refs/remotes/origin/HEAD")
+func getRemoteDefaultBranch(repoRoot string) string {
+	cmd := gitCommand(repoRoot, "symbolic-ref", "refs/remotes/origin/HEAD")
 	output, err := cmd.Output()
 	if err != nil {
 		return ""
@@ -267,3 +778,11 @@ func getRemoteDefaultBranch() string {
 
 	return ""
 }
+
+// gitCommand returns a git command that runs in dir. An empty dir means the
+// current directory.
+func gitCommand(dir string, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd
+}
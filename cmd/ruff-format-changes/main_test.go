@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/ruff"
+	"github.com/horiagug/ruff-format-changes/internal/testutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 
@@ -71,7 +81,7 @@ func TestBranchExists(t *testing.T) {
 
 			exec.Command("git", "checkout", "main").Run()
 
-			exists := branchExists(tt.branch)
+			exists := branchExists("", tt.branch)
 			if exists != tt.shouldExist {
 				t.Errorf("branchExists(%q) = %v, want %v", tt.branch, exists, tt.shouldExist)
 			}
@@ -111,13 +121,13 @@ func TestDetermineBaseBranchWithMaster(t *testing.T) {
 		t.Fatalf("Failed to create feature branch: %v", err)
 	}
 
-	baseBranch := determineBaseBranch(nil)
+	baseBranch := determineBaseBranch("")
 
 	if baseBranch != "master" && baseBranch != "main" {
 		t.Errorf("determineBaseBranch() = %q, want either 'master' or 'main' (found master exists)", baseBranch)
 	}
 
-	if !branchExists("master") {
+	if !branchExists("", "master") {
 		t.Errorf("Expected master branch to exist")
 	}
 }
@@ -150,7 +160,7 @@ func TestDetermineBaseBranchWithMain(t *testing.T) {
 		t.Fatalf("Failed to create feature branch: %v", err)
 	}
 
-	baseBranch := determineBaseBranch(nil)
+	baseBranch := determineBaseBranch("")
 
 	if baseBranch != "main" {
 		t.Errorf("determineBaseBranch() = %q, want 'main'", baseBranch)
@@ -185,7 +195,7 @@ func TestDetermineBaseBranchWithDevelop(t *testing.T) {
 		t.Fatalf("Failed to create feature branch: %v", err)
 	}
 
-	baseBranch := determineBaseBranch(nil)
+	baseBranch := determineBaseBranch("")
 
 	if baseBranch != "develop" {
 		t.Errorf("determineBaseBranch() = %q, want 'develop'", baseBranch)
@@ -224,7 +234,7 @@ func TestFindParentBranchFromMain(t *testing.T) {
 		t.Fatalf("Failed to create feature commit: %v", err)
 	}
 
-	parentBranch := findParentBranch()
+	parentBranch := findParentBranch("")
 
 	if parentBranch != "main" {
 		t.Errorf("findParentBranch() = %q, want 'main'", parentBranch)
@@ -265,7 +275,7 @@ func TestFindParentBranchFromMaster(t *testing.T) {
 		t.Fatalf("Failed to create feature commit: %v", err)
 	}
 
-	parentBranch := findParentBranch()
+	parentBranch := findParentBranch("")
 
 	if parentBranch != "master" {
 		t.Errorf("findParentBranch() = %q, want 'master'", parentBranch)
@@ -300,7 +310,7 @@ func TestFindParentBranchFromNonStandardBranch(t *testing.T) {
 		t.Fatalf("Failed to create feature branch: %v", err)
 	}
 
-	parentBranch := findParentBranch()
+	parentBranch := findParentBranch("")
 
 	if parentBranch != "develop" {
 		t.Errorf("findParentBranch() = %q, want 'develop'", parentBranch)
@@ -328,3 +338,896 @@ func createEmptyCommit(branchName string) error {
 	cmd := exec.Command("git", "commit", "-m", "Initial commit on "+branchName)
 	return cmd.Run()
 }
+
+// TestParseRevisionOptions tests validation of the revision range flags
+func TestParseRevisionOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     options
+		expected string
+		wantErr  bool
+	}{
+		{name: "no range", opts: options{}, expected: ""},
+		{name: "range flag", opts: options{revRange: "origin/main...HEAD"}, expected: "origin/main...HEAD"},
+		{name: "from and to", opts: options{fromRev: "v1.0", toRev: "v2.0"}, expected: "v1.0..v2.0"},
+		{name: "from only", opts: options{fromRev: "HEAD~1"}, expected: "HEAD~1"},
+		{name: "to without from", opts: options{toRev: "HEAD"}, wantErr: true},
+		{name: "range with from", opts: options{revRange: "a..b", fromRev: "a"}, wantErr: true},
+		{name: "range with base", opts: options{revRange: "a..b", baseBranch: "main", baseSet: true}, wantErr: true},
+		{name: "range with configured base", opts: options{revRange: "a..b", baseBranch: "main"}, expected: "a..b"},
+		{name: "range with staged", opts: options{revRange: "a..b", staged: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, err := parseRevisionOptions(tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %v", rr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := ""
+			if rr != nil {
+				got = rr.String()
+			}
+			if got != tt.expected {
+				t.Errorf("parseRevisionOptions() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestNewFormatter tests selection of the formatter backend
+func TestNewFormatter(t *testing.T) {
+	for _, name := range formatterNames {
+		f, err := newFormatter(name, "/tmp/repo", false, false)
+		if err != nil {
+			t.Fatalf("newFormatter(%q): unexpected error %v", name, err)
+		}
+		if f.Name() != name {
+			t.Errorf("newFormatter(%q).Name() = %q", name, f.Name())
+		}
+	}
+
+	if _, err := newFormatter("autopep8", "/tmp/repo", false, false); err == nil {
+		t.Errorf("Expected error for unknown formatter")
+	}
+}
+
+// TestFormatDiagnostic tests rendering of diagnostics reported by the check subcommand
+func TestFormatDiagnostic(t *testing.T) {
+	d := ruff.Diagnostic{
+		Code:     "F401",
+		Message:  "`os` imported but unused",
+		Filename: "/repo/pkg/main.py",
+		Location: ruff.Location{Row: 3, Column: 8},
+		Fix:      &ruff.Fix{Applicability: "safe"},
+	}
+
+	expected := "pkg/main.py:3:8: F401 `os` imported but unused [*]"
+	if got := formatDiagnostic("/repo", d); got != expected {
+		t.Errorf("formatDiagnostic() = %q, want %q", got, expected)
+	}
+
+	d.Code = ""
+	d.Fix = nil
+	expected = "pkg/main.py:3:8: error `os` imported but unused"
+	if got := formatDiagnostic("/repo", d); got != expected {
+		t.Errorf("formatDiagnostic() = %q, want %q", got, expected)
+	}
+}
+
+// setupFeatureRepo creates a repository with a Python change on a feature branch
+// and changes into it
+func setupFeatureRepo(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(originalDir) })
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change to temp directory: %v", err)
+	}
+
+	if err := exec.Command("git", "init").Run(); err != nil {
+		t.Fatalf("Failed to init git repo: %v", err)
+	}
+
+	exec.Command("git", "config", "user.email", "test@example.com").Run()
+	exec.Command("git", "config", "user.name", "Test User").Run()
+
+	if err := createEmptyCommit("main"); err != nil {
+		t.Fatalf("Failed to create main commit: %v", err)
+	}
+
+	if err := exec.Command("git", "checkout", "-b", "feature/test").Run(); err != nil {
+		t.Fatalf("Failed to create feature branch: %v", err)
+	}
+
+	if err := os.WriteFile("main.py", []byte("x=1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+
+	return tmpDir
+}
+
+// TestRunCheckModeExitCodes tests the exit codes reported by --check
+func TestRunCheckModeExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected int
+		wantErr  bool
+	}{
+		{name: "clean", script: "exit 0", expected: exitClean},
+		{name: "needs formatting", script: `case "$*" in *--version*) exit 0;; esac; echo "+x = 1"; exit 1`, expected: exitNeedsFormatting},
+		{name: "ruff error", script: `case "$*" in *--version*) exit 0;; esac; echo "error: Failed to parse" >&2; exit 2`, expected: exitToolError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupFeatureRepo(t)
+			testutil.InstallFakeTool(t, "ruff", tt.script)
+
+			opts := options{baseBranch: "main", formatter: "ruff", outputFmt: "text", check: true, dryRun: true}
+			code, err := runCheckMode(opts)
+			if code != tt.expected {
+				t.Errorf("runCheckMode() = %d, want %d (err: %v)", code, tt.expected, err)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runCheckMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRunCheckModeMissingFormatter tests that a missing formatter is a tool error
+func TestRunCheckModeMissingFormatter(t *testing.T) {
+	setupFeatureRepo(t)
+	t.Setenv("PATH", t.TempDir()+string(os.PathListSeparator)+"/usr/bin:/bin")
+
+	opts := options{baseBranch: "main", formatter: "black", outputFmt: "text", check: true, dryRun: true}
+	code, err := runCheckMode(opts)
+	if code != exitToolError || err == nil {
+		t.Errorf("runCheckMode() = %d, %v; want %d with an error", code, err, exitToolError)
+	}
+}
+
+// TestRunCommandPatch tests that --patch writes one patch for all files that git can apply
+func TestRunCommandPatch(t *testing.T) {
+	tmpDir := setupFeatureRepo(t)
+	if err := os.MkdirAll("pkg", 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join("pkg", "util.py"), []byte("y=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+
+	// The fake ruff adds spaces around "=" when reading stdin
+	testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac; sed 's/=/ = /'`)
+
+	patchFile := filepath.Join(t.TempDir(), "format.patch")
+	opts := options{baseBranch: "main", formatter: "ruff", outputFmt: "text", patch: patchFile}
+	needsFormatting, err := runCommand(opts)
+	if err != nil {
+		t.Fatalf("runCommand() failed: %v", err)
+	}
+	if !needsFormatting {
+		t.Errorf("Expected the patch to report changes")
+	}
+
+	patch, err := os.ReadFile(patchFile)
+	if err != nil {
+		t.Fatalf("Failed to read patch: %v", err)
+	}
+
+	// Files appear in path order and the working tree is untouched
+	if strings.Index(string(patch), "a/main.py") > strings.Index(string(patch), "a/pkg/util.py") {
+		t.Errorf("Expected files in path order, got:\n%s", patch)
+	}
+	if content, _ := os.ReadFile("main.py"); string(content) != "x=1\n" {
+		t.Errorf("Expected main.py to be unchanged, got %q", content)
+	}
+
+	cmd := exec.Command("git", "apply", patchFile)
+	cmd.Dir = tmpDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git apply failed: %v: %s", err, output)
+	}
+	if content, _ := os.ReadFile("main.py"); string(content) != "x = 1\n" {
+		t.Errorf("Expected patched main.py, got %q", content)
+	}
+}
+
+// TestRunCommandRepoDir tests running against a repository outside the current directory
+func TestRunCommandRepoDir(t *testing.T) {
+	tmpDir := setupFeatureRepo(t)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+
+	if baseBranch := determineBaseBranch(tmpDir); baseBranch != "main" {
+		t.Errorf("determineBaseBranch(%q) = %q, want 'main'", tmpDir, baseBranch)
+	}
+
+	testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac; echo "+x = 1"; exit 1`)
+
+	opts := options{repoDir: tmpDir, formatter: "ruff", outputFmt: "text", dryRun: true}
+	code, err := runCheckMode(opts)
+	if err != nil {
+		t.Fatalf("runCheckMode() failed: %v", err)
+	}
+	if code != exitNeedsFormatting {
+		t.Errorf("runCheckMode() = %d, want %d", code, exitNeedsFormatting)
+	}
+}
+
+// TestRunCommandStdinRequiresRuff tests that --stdin is rejected for other backends
+func TestRunCommandStdinRequiresRuff(t *testing.T) {
+	setupFeatureRepo(t)
+
+	opts := options{baseBranch: "main", formatter: "black", outputFmt: "text", stdin: true}
+	_, err := runCommand(opts)
+	if err == nil || !strings.Contains(err.Error(), "--stdin") {
+		t.Errorf("Expected --stdin error, got %v", err)
+	}
+}
+
+// TestRunCommandRollback tests that a formatter failure restores every changed file
+func TestRunCommandRollback(t *testing.T) {
+	tests := []struct {
+		name       string
+		noRollback bool
+		expected   string
+	}{
+		{name: "rollback", expected: "x=1\n"},
+		{name: "no rollback", noRollback: true, expected: "x = 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupFeatureRepo(t)
+			if err := os.WriteFile("util.py", []byte("y=2\n"), 0644); err != nil {
+				t.Fatalf("Failed to write Python file: %v", err)
+			}
+
+			// The fake ruff formats main.py and fails on util.py
+			testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac
+for a in "$@"; do f="$a"; done
+case "$f" in *util.py) echo "error: Failed to parse" >&2; exit 2;; esac
+echo "x = 1" > "$f"`)
+
+			opts := options{baseBranch: "main", formatter: "ruff", outputFmt: "text", jobs: 1, noRollback: tt.noRollback}
+			if _, err := runCommand(opts); err == nil {
+				t.Fatalf("Expected formatter failure, got nil")
+			}
+
+			if content, _ := os.ReadFile("main.py"); string(content) != tt.expected {
+				t.Errorf("Expected main.py to be %q, got %q", tt.expected, content)
+			}
+		})
+	}
+}
+
+// TestRunCommandStrictRanges tests that edits outside the changed lines are reverted or reported
+func TestRunCommandStrictRanges(t *testing.T) {
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{mode: strictRevert, expected: "a=1\nb = 2\nc=3\n"},
+		{mode: strictReport, expected: "a = 1\nb = 2\nc = 3\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			setupFeatureRepo(t)
+			if err := os.WriteFile("lib.py", []byte("a=1\nb=1\nc=3\n"), 0644); err != nil {
+				t.Fatalf("Failed to write Python file: %v", err)
+			}
+			exec.Command("git", "add", "lib.py").Run()
+			if err := exec.Command("git", "commit", "-m", "Add lib.py").Run(); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+			if err := os.WriteFile("lib.py", []byte("a=1\nb=2\nc=3\n"), 0644); err != nil {
+				t.Fatalf("Failed to modify Python file: %v", err)
+			}
+
+			// The fake ruff reformats the whole file whatever range it is given
+			testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac
+for a in "$@"; do f="$a"; done
+sed 's/=/ = /' "$f" > "$f.tmp" && mv "$f.tmp" "$f"`)
+
+			opts := options{baseBranch: "HEAD", noMergeBase: true, formatter: "ruff", outputFmt: "text", strictRanges: tt.mode}
+			if _, err := runCommand(opts); err != nil {
+				t.Fatalf("runCommand() failed: %v", err)
+			}
+
+			if content, _ := os.ReadFile("lib.py"); string(content) != tt.expected {
+				t.Errorf("Expected lib.py to be %q, got %q", tt.expected, content)
+			}
+		})
+	}
+}
+
+// TestValidateStrictRanges tests the options accepted with --strict-ranges
+func TestValidateStrictRanges(t *testing.T) {
+	tests := []struct {
+		opts    options
+		wantErr bool
+	}{
+		{opts: options{}},
+		{opts: options{strictRanges: strictRevert}},
+		{opts: options{strictRanges: strictReport, dryRun: true, patch: "-"}},
+		{opts: options{strictRanges: "sometimes"}, wantErr: true},
+		{opts: options{strictRanges: strictRevert, dryRun: true}, wantErr: true},
+		{opts: options{strictRanges: strictRevert, staged: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := validateStrictRanges(tt.opts); (err != nil) != tt.wantErr {
+			t.Errorf("validateStrictRanges(%+v) error = %v, wantErr %v", tt.opts, err, tt.wantErr)
+		}
+	}
+}
+
+// TestRunCommandFileSelection tests that changed files are selected by extension,
+// shebang and exclude globs, including ruff's extend-exclude
+func TestRunCommandFileSelection(t *testing.T) {
+	setupFeatureRepo(t)
+	files := map[string]string{
+		"stubs.pyi":              "y=2\n",
+		"bin/manage":             "#!/usr/bin/env python3\nz=3\n",
+		"bin/deploy.sh":          "#!/bin/sh\nw=4\n",
+		"app/migrations/0001.py": "m=5\n",
+		"vendor/six.py":          "v=6\n",
+		"ruff.toml":              "extend-exclude = [\"migrations\"]\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac; sed 's/=/ = /'`)
+
+	patchFile := filepath.Join(t.TempDir(), "format.patch")
+	opts := options{baseBranch: "main", formatter: "ruff", outputFmt: "text", patch: patchFile, exclude: []string{"vendor/**"}}
+	if _, err := runCommand(opts); err != nil {
+		t.Fatalf("runCommand() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(patchFile)
+	if err != nil {
+		t.Fatalf("Failed to read patch: %v", err)
+	}
+	patch := string(content)
+
+	for _, name := range []string{"main.py", "stubs.pyi", "bin/manage"} {
+		if !strings.Contains(patch, "+++ b/"+name) {
+			t.Errorf("Expected %s in the patch, got:\n%s", name, patch)
+		}
+	}
+	for _, name := range []string{"bin/deploy.sh", "app/migrations/0001.py", "vendor/six.py"} {
+		if strings.Contains(patch, name) {
+			t.Errorf("Expected %s to be skipped, got:\n%s", name, patch)
+		}
+	}
+}
+
+// TestConfigShow tests that config show prints every setting with its source
+func TestConfigShow(t *testing.T) {
+	setupFeatureRepo(t)
+	pyproject := "[tool.ruff-format-changes]\nformatter = \"black\"\nexclude = [\"migrations\"]\njobs = 3\n"
+	if err := os.WriteFile("pyproject.toml", []byte(pyproject), 0644); err != nil {
+		t.Fatalf("Failed to write pyproject.toml: %v", err)
+	}
+	t.Setenv("RUFF_FORMAT_CHANGES_JOBS", "5")
+
+	var opts options
+	rootCmd := &cobra.Command{Use: "ruff-format-changes"}
+	rootCmd.PersistentFlags().StringVar(&opts.baseBranch, "base", "", "")
+	rootCmd.PersistentFlags().StringArrayVar(&opts.exclude, "exclude", nil, "")
+	rootCmd.Flags().StringVar(&opts.formatter, "formatter", "ruff", "")
+	rootCmd.Flags().IntVar(&opts.jobs, "jobs", 8, "")
+	rootCmd.Flags().StringVar(&opts.outputFmt, "output-format", "text", "")
+	rootCmd.AddCommand(newConfigCommand(&opts))
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"config", "show", "--base", "develop"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("config show failed: %v", err)
+	}
+
+	expected := []string{
+		"# Configuration file: ",
+		`base = "develop"          # flag`,
+		`formatter = "black"       # pyproject.toml`,
+		`exclude = ["migrations"]  # pyproject.toml`,
+		`jobs = 5                  # RUFF_FORMAT_CHANGES_JOBS`,
+		`output-format = "text"    # default`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected %q in output, got:\n%s", line, out.String())
+		}
+	}
+	if opts.formatter != "black" || opts.jobs != 5 {
+		t.Errorf("Expected options to be configured, got formatter %q and %d jobs", opts.formatter, opts.jobs)
+	}
+}
+
+// TestCollectFileChangesRenames tests that a moved file only reports the lines
+// that differ from its old path unless rename detection is disabled
+func TestCollectFileChangesRenames(t *testing.T) {
+	setupFeatureRepo(t)
+	content := "a = 1\nb = 2\nc = 3\nd = 4\n"
+	if err := os.WriteFile("main.py", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+	if err := exec.Command("git", "add", "main.py").Run(); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	if err := exec.Command("git", "commit", "-m", "Add main.py").Run(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if err := os.Remove("main.py"); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if err := os.WriteFile("moved.py", []byte(content+"e=5\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		noRenames bool
+		expected  git.FileChanges
+	}{
+		{name: "renames", expected: git.FileChanges{FilePath: "moved.py", LineRanges: []git.LineRange{{Start: 5, End: 5}}, OldPath: "main.py"}},
+		{name: "no renames", noRenames: true, expected: git.FileChanges{FilePath: "moved.py", LineRanges: []git.LineRange{{Start: 1, End: 5}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := options{baseBranch: "HEAD", noMergeBase: true, noRenames: tt.noRenames}
+			gitClient, err := newGitClient(opts)
+			if err != nil {
+				t.Fatalf("newGitClient() failed: %v", err)
+			}
+
+			fileChanges, err := collectFileChanges(gitClient, opts, nil, false)
+			if err != nil {
+				t.Fatalf("collectFileChanges() failed: %v", err)
+			}
+			if len(fileChanges) != 1 || !reflect.DeepEqual(fileChanges[0], tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, fileChanges)
+			}
+		})
+	}
+}
+
+// TestCollectFileChangesSeams tests that deleting lines reports the lines
+// around the deletion according to --seams, and nothing by default
+func TestCollectFileChangesSeams(t *testing.T) {
+	setupFeatureRepo(t)
+	if err := os.WriteFile("main.py", []byte("call(\n    a,\n    b,\n)\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+	if err := exec.Command("git", "add", "main.py").Run(); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	if err := exec.Command("git", "commit", "-m", "Add main.py").Run(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if err := os.WriteFile("main.py", []byte("call(\n    a,\n)\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+
+	tests := []struct {
+		seams    string
+		expected []git.LineRange
+		wantErr  bool
+	}{
+		{seams: ""},
+		{seams: "off"},
+		{seams: "line", expected: []git.LineRange{{Start: 2, End: 3}}},
+		{seams: "statement", expected: []git.LineRange{{Start: 1, End: 3}}},
+		{seams: "block", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.seams, func(t *testing.T) {
+			opts := options{baseBranch: "HEAD", noMergeBase: true, seams: tt.seams}
+			gitClient, err := newGitClient(opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error for invalid --seams, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newGitClient() failed: %v", err)
+			}
+
+			fileChanges, err := collectFileChanges(gitClient, opts, nil, false)
+			if err != nil {
+				t.Fatalf("collectFileChanges() failed: %v", err)
+			}
+			var ranges []git.LineRange
+			for _, fc := range fileChanges {
+				ranges = append(ranges, fc.LineRanges...)
+			}
+			if !reflect.DeepEqual(ranges, tt.expected) {
+				t.Errorf("Expected ranges %v, got %v", tt.expected, ranges)
+			}
+		})
+	}
+}
+
+// TestCollectFileChangesExpand tests that --expand widens the changed lines
+func TestCollectFileChangesExpand(t *testing.T) {
+	setupFeatureRepo(t)
+	content := "def f():\n    x = 1\n    return x\n\n\ny = 2\n"
+	if err := os.WriteFile("main.py", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+	if err := exec.Command("git", "add", "main.py").Run(); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	if err := exec.Command("git", "commit", "-m", "Add main.py").Run(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if err := os.WriteFile("main.py", []byte(strings.Replace(content, "x = 1", "x=2", 1)), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+
+	tests := []struct {
+		expand   string
+		expected []git.LineRange
+		wantErr  bool
+	}{
+		{expand: "", expected: []git.LineRange{{Start: 2, End: 2}}},
+		{expand: "function", expected: []git.LineRange{{Start: 1, End: 3}}},
+		{expand: "module", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expand, func(t *testing.T) {
+			opts := options{baseBranch: "HEAD", noMergeBase: true, expand: tt.expand}
+			gitClient, err := newGitClient(opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error for invalid --expand, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newGitClient() failed: %v", err)
+			}
+
+			fileChanges, err := collectFileChanges(gitClient, opts, nil, false)
+			if err != nil {
+				t.Fatalf("collectFileChanges() failed: %v", err)
+			}
+			if len(fileChanges) != 1 || !reflect.DeepEqual(fileChanges[0].LineRanges, tt.expected) {
+				t.Errorf("Expected ranges %v, got %+v", tt.expected, fileChanges)
+			}
+		})
+	}
+}
+
+// TestNewGitClientInvalidMergeGap tests that a negative --merge-gap is rejected
+func TestNewGitClientInvalidMergeGap(t *testing.T) {
+	setupFeatureRepo(t)
+
+	if _, err := newGitClient(options{mergeGap: -1}); err == nil {
+		t.Error("Expected error for negative --merge-gap, got nil")
+	}
+	if _, err := newGitClient(options{mergeGap: 3}); err != nil {
+		t.Errorf("Expected no error for --merge-gap 3, got %v", err)
+	}
+}
+
+// TestNewGitClientSimilarity tests that an explicit --similarity 0 is rejected
+// instead of being replaced by the default
+func TestNewGitClientSimilarity(t *testing.T) {
+	setupFeatureRepo(t)
+
+	if _, err := newGitClient(options{similarity: 0, similaritySet: true}); err == nil {
+		t.Error("Expected error for --similarity 0, got nil")
+	}
+	if _, err := newGitClient(options{}); err != nil {
+		t.Errorf("Expected an unset similarity to keep the default, got %v", err)
+	}
+	if _, err := newGitClient(options{similarity: 30, similaritySet: true}); err != nil {
+		t.Errorf("Expected no error for --similarity 30, got %v", err)
+	}
+}
+
+// TestConfiguredBaseWithRange tests that a base from the configuration does not
+// conflict with a revision range given on the command line
+func TestConfiguredBaseWithRange(t *testing.T) {
+	setupFeatureRepo(t)
+	if err := os.WriteFile("pyproject.toml", []byte("[tool.ruff-format-changes]\nbase = \"main\"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write pyproject.toml: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr bool
+	}{
+		{name: "range", args: []string{"--range", "main..HEAD"}},
+		{name: "from", args: []string{"--from", "main"}},
+		{name: "range and base flag", args: []string{"--range", "main..HEAD", "--base", "main"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts options
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.StringVar(&opts.baseBranch, "base", "", "")
+			flags.StringVar(&opts.revRange, "range", "", "")
+			flags.StringVar(&opts.fromRev, "from", "", "")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatalf("Failed to parse flags: %v", err)
+			}
+
+			if _, _, err := applyConfig(opts, flags); err != nil {
+				t.Fatalf("Failed to apply configuration: %v", err)
+			}
+			if opts.baseBranch != "main" {
+				t.Fatalf("Expected base main from the configuration, got %q", opts.baseBranch)
+			}
+			opts.baseSet = flags.Changed("base")
+
+			_, err := parseRevisionOptions(opts)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestResolveMergeBaseUnrelated tests that a base without a merge base falls back
+// to its tip, except under --check, where it is a tool error
+func TestResolveMergeBaseUnrelated(t *testing.T) {
+	setupFeatureRepo(t)
+	testutil.InstallFakeTool(t, "ruff", "exit 0")
+	if err := exec.Command("git", "checkout", "--orphan", "unrelated").Run(); err != nil {
+		t.Fatalf("Failed to create orphan branch: %v", err)
+	}
+	if err := exec.Command("git", "commit", "--allow-empty", "-m", "Unrelated").Run(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if err := exec.Command("git", "checkout", "feature/test").Run(); err != nil {
+		t.Fatalf("Failed to check out feature branch: %v", err)
+	}
+
+	gitClient, err := newGitClient(options{})
+	if err != nil {
+		t.Fatalf("newGitClient() failed: %v", err)
+	}
+	if base, err := resolveMergeBase(gitClient, "unrelated", false, false, io.Discard); err != nil || base != "unrelated" {
+		t.Errorf("resolveMergeBase() = %q, %v; want the branch tip", base, err)
+	}
+	if _, err := resolveMergeBase(gitClient, "unrelated", true, false, io.Discard); err == nil {
+		t.Error("Expected error in strict mode, got nil")
+	}
+
+	opts := options{baseBranch: "unrelated", formatter: "ruff", outputFmt: "text", check: true, dryRun: true}
+	if code, err := runCheckMode(opts); code != exitToolError || err == nil {
+		t.Errorf("runCheckMode() = %d, %v; want %d with an error", code, err, exitToolError)
+	}
+}
+
+// TestRunCommandRangeContent tests that read-only runs over a revision range
+// format the files at its end, while in-place runs refuse files that differ from it
+func TestRunCommandRangeContent(t *testing.T) {
+	setupFeatureRepo(t)
+	for _, content := range []string{"x=1\n", "x = 1\n"} {
+		if err := os.WriteFile("main.py", []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write Python file: %v", err)
+		}
+		if err := exec.Command("git", "add", "main.py").Run(); err != nil {
+			t.Fatalf("Failed to add file: %v", err)
+		}
+		if err := exec.Command("git", "commit", "-m", "Change main.py").Run(); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+	// Uncommitted noise that must not be formatted or shift the line numbers
+	if err := os.WriteFile("main.py", []byte("# local\nx = 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+
+	testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac; sed 's/x=/x = /'`)
+
+	// HEAD~1 still has the unformatted line
+	code, err := runCheckMode(options{revRange: "main..HEAD~1", formatter: "ruff", outputFmt: "text", check: true, dryRun: true})
+	if code != exitNeedsFormatting || err != nil {
+		t.Errorf("runCheckMode() = %d, %v; want %d", code, err, exitNeedsFormatting)
+	}
+	code, err = runCheckMode(options{revRange: "main..HEAD", formatter: "ruff", outputFmt: "text", check: true, dryRun: true})
+	if code != exitClean || err != nil {
+		t.Errorf("runCheckMode() = %d, %v; want %d", code, err, exitClean)
+	}
+
+	patchFile := filepath.Join(t.TempDir(), "format.patch")
+	if _, err := runCommand(options{revRange: "main..HEAD~1", formatter: "ruff", outputFmt: "text", patch: patchFile}); err != nil {
+		t.Fatalf("runCommand() failed: %v", err)
+	}
+	patch, err := os.ReadFile(patchFile)
+	if err != nil {
+		t.Fatalf("Failed to read patch: %v", err)
+	}
+	if !strings.Contains(string(patch), "-x=1\n+x = 1\n") || strings.Contains(string(patch), "# local") {
+		t.Errorf("Expected a patch of main.py at HEAD~1, got:\n%s", patch)
+	}
+
+	for _, revRange := range []string{"main..HEAD~1", "main..HEAD"} {
+		if _, err := runCommand(options{revRange: revRange, formatter: "ruff", outputFmt: "text"}); err == nil {
+			t.Errorf("Expected formatting %s in place to fail", revRange)
+		}
+	}
+	if content, _ := os.ReadFile("main.py"); string(content) != "# local\nx = 1\n" {
+		t.Errorf("Expected main.py to be unchanged, got %q", content)
+	}
+}
+
+// TestRunCheckFixRecollectsRanges tests that the lint after --fix filters
+// diagnostics with the changed lines as they are after the fixes
+func TestRunCheckFixRecollectsRanges(t *testing.T) {
+	setupFeatureRepo(t)
+	if err := os.WriteFile("main.py", []byte("a = 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+	if err := exec.Command("git", "add", "main.py").Run(); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	if err := exec.Command("git", "commit", "-m", "Add main.py").Run(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	// Lines 1 and 3 change; removing the import moves line 3 to line 2
+	if err := os.WriteFile("main.py", []byte("import os\na = 1\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+
+	testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac
+for file; do :; done
+if grep -q "import os" "$file"; then
+	echo '[{"code":"F401","message":"unused import","filename":"'"$file"'","location":{"row":1,"column":1},"end_location":{"row":1,"column":10},"fix":{"applicability":"safe","message":"Remove import","edits":[{"content":"","location":{"row":1,"column":1},"end_location":{"row":2,"column":1}}]}}]'
+else
+	echo '[{"code":"E225","message":"missing whitespace","filename":"'"$file"'","location":{"row":2,"column":2},"end_location":{"row":2,"column":3}}]'
+fi`)
+
+	opts := options{baseBranch: "HEAD", noMergeBase: true}
+	err := runCheck(opts, checkOptions{fix: true})
+	if err == nil || !strings.Contains(err.Error(), "found 1 diagnostic(s)") {
+		t.Errorf("Expected the diagnostic on the moved line to be reported, got %v", err)
+	}
+	if content, _ := os.ReadFile("main.py"); string(content) != "a = 1\nb=2\n" {
+		t.Errorf("Expected the import to be removed, got %q", content)
+	}
+}
+
+// TestRunCheckProgress tests that the check subcommand writes only diagnostics
+// to stdout and its progress messages to stderr with machine readable output
+func TestRunCheckProgress(t *testing.T) {
+	setupFeatureRepo(t)
+	testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac
+for file; do :; done
+echo '[{"code":"E225","message":"missing whitespace","filename":"'"$file"'","location":{"row":1,"column":2},"end_location":{"row":1,"column":3}}]'`)
+
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	originalStdout, originalStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, stderr
+	err = runCheck(options{baseBranch: "main", outputFmt: "json", verbose: true, configPath: "pyproject.toml"}, checkOptions{})
+	os.Stdout, os.Stderr = originalStdout, originalStderr
+	if err == nil || !strings.Contains(err.Error(), "found 1 diagnostic(s)") {
+		t.Fatalf("Expected one diagnostic, got %v", err)
+	}
+
+	if output, _ := os.ReadFile(stdout.Name()); string(output) != "main.py:1:2: E225 missing whitespace\n" {
+		t.Errorf("Expected only the diagnostic on stdout, got %q", output)
+	}
+	progress, _ := os.ReadFile(stderr.Name())
+	for _, expected := range []string{"Using configuration from pyproject.toml", "Running: ruff check"} {
+		if !strings.Contains(string(progress), expected) {
+			t.Errorf("Expected %q on stderr, got %q", expected, progress)
+		}
+	}
+}
+
+// TestRunCommandJSONProgress tests that with machine readable output only the
+// report is written to stdout and progress messages go to stderr
+func TestRunCommandJSONProgress(t *testing.T) {
+	setupFeatureRepo(t)
+	testutil.InstallFakeTool(t, "ruff", "exit 0")
+
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	stderr, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	originalStdout, originalStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, stderr
+	_, err = runCommand(options{baseBranch: "main", formatter: "ruff", outputFmt: "json", dryRun: true, verbose: true})
+	os.Stdout, os.Stderr = originalStdout, originalStderr
+	if err != nil {
+		t.Fatalf("runCommand() failed: %v", err)
+	}
+
+	report, _ := os.ReadFile(stdout.Name())
+	var decoded map[string]any
+	if err := json.Unmarshal(report, &decoded); err != nil {
+		t.Errorf("Expected only the JSON report on stdout, got %q: %v", report, err)
+	}
+	if progress, _ := os.ReadFile(stderr.Name()); !strings.Contains(string(progress), "Running: ruff format") {
+		t.Errorf("Expected progress messages on stderr, got %q", progress)
+	}
+}
+
+// TestRunStagedProgress tests that --staged writes its messages to the progress
+// writer and leaves stdout alone
+func TestRunStagedProgress(t *testing.T) {
+	setupFeatureRepo(t)
+	testutil.InstallFakeTool(t, "ruff", `case "$*" in *--version*) exit 0;; esac; sed 's/=/ = /'`)
+	if err := os.WriteFile("main.py", []byte("x=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to write Python file: %v", err)
+	}
+	if err := exec.Command("git", "add", "main.py").Run(); err != nil {
+		t.Fatalf("Failed to stage file: %v", err)
+	}
+
+	gitClient, err := git.New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+	fmtr, err := newFormatter("ruff", gitClient.GetRepoRoot(), false, true)
+	if err != nil {
+		t.Fatalf("newFormatter() failed: %v", err)
+	}
+
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	var progress bytes.Buffer
+	fmtr.SetLog(&progress)
+	originalStdout := os.Stdout
+	os.Stdout = stdout
+	_, err = runStaged(gitClient, fmtr, false, true, &progress)
+	os.Stdout = originalStdout
+	if err != nil {
+		t.Fatalf("runStaged() failed: %v", err)
+	}
+
+	if output, _ := os.ReadFile(stdout.Name()); len(output) != 0 {
+		t.Errorf("Expected nothing on stdout, got %q", output)
+	}
+	if !strings.Contains(progress.String(), "Formatted and re-staged: main.py") {
+		t.Errorf("Expected progress messages in the progress writer, got %q", progress.String())
+	}
+}
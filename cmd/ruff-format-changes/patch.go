@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/horiagug/ruff-format-changes/internal/diff"
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/output"
+)
+
+// patchContext is the number of context lines around each hunk of a patch
+const patchContext = 3
+
+// buildPatch formats the content returned by read for every file in memory and
+// returns a single unified diff covering all files and ranges, ordered by path,
+// along with the number of files it changes. Files on disk are not modified. With
+// a strict ranges mode, edits outside the changed ranges are reported and, in
+// revert mode, left out.
+func buildPatch(fmtr formatter.Formatter, fileChanges []git.FileChanges, read func(path string) ([]byte, error), strictRanges string, progress io.Writer) (string, int, error) {
+	sorted := make([]git.FileChanges, len(fileChanges))
+	copy(sorted, fileChanges)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].FilePath < sorted[j].FilePath
+	})
+
+	var patch strings.Builder
+	changed := 0
+	for _, fc := range sorted {
+		original, err := read(fc.FilePath)
+		if err != nil {
+			return "", changed, err
+		}
+
+		formatted, err := fmtr.FormatContentByLineRanges(fc.FilePath, original, fc.LineRanges)
+		if err != nil {
+			return "", changed, err
+		}
+
+		if strictRanges != "" {
+			enforced, outside := formatter.EnforceRanges(original, formatted, fc.LineRanges)
+			for _, lr := range outside {
+				fmt.Fprintf(progress, "Warning: %s edits %s outside the changed lines\n", fc.FilePath, output.DescribeRange(lr))
+			}
+			if strictRanges == strictRevert {
+				formatted = enforced
+			}
+		}
+
+		if fileDiff := diff.Unified(filepath.ToSlash(fc.FilePath), string(original), string(formatted), patchContext); fileDiff != "" {
+			patch.WriteString(fileDiff)
+			changed++
+		}
+	}
+
+	return patch.String(), changed, nil
+}
+
+// formatInMemory formats the content returned by read for every file on a pool
+// of up to jobs workers and reports the outcome as a dry run would, without
+// touching the files on disk
+func formatInMemory(fmtr formatter.Formatter, fileChanges []git.FileChanges, read func(path string) ([]byte, error), jobs int, progress io.Writer) (*formatter.Report, error) {
+	report := &formatter.Report{Formatter: fmtr.Name(), DryRun: true}
+	started := time.Now()
+
+	report.Files = formatter.FormatFiles(fileChanges, jobs, progress, func(fc git.FileChanges, log io.Writer) formatter.FileResult {
+		result := formatter.FileResult{FilePath: fc.FilePath}
+		fileStarted := time.Now()
+
+		statuses := make([]formatter.Status, len(fc.LineRanges))
+		for i := range statuses {
+			statuses[i] = formatter.StatusUnchanged
+		}
+
+		original, err := read(fc.FilePath)
+		if err == nil {
+			result.BeforeHash = formatter.HashContent(original)
+			result.AfterHash = result.BeforeHash
+
+			var formatted []byte
+			formatted, err = fmtr.FormatContentByLineRanges(fc.FilePath, original, fc.LineRanges)
+			if err == nil && !bytes.Equal(original, formatted) {
+				statuses = formatter.ContentRangeStatuses(original, formatted, fc.LineRanges, formatter.StatusWouldReformat)
+				result.Diff = diff.Unified(filepath.ToSlash(fc.FilePath), string(original), string(formatted), patchContext)
+			}
+		}
+
+		var errMsg string
+		if err != nil {
+			for i := range statuses {
+				statuses[i] = formatter.StatusFailed
+			}
+			errMsg = err.Error()
+		}
+
+		result.Duration = time.Since(fileStarted)
+		for i, lr := range fc.LineRanges {
+			result.Ranges = append(result.Ranges, formatter.RangeResult{
+				Range:    lr,
+				Status:   statuses[i],
+				Error:    errMsg,
+				Duration: result.Duration,
+			})
+		}
+		return result
+	})
+
+	report.Duration = time.Since(started)
+	return report, formatter.FailureError(report)
+}
+
+// readWorkingTree returns a function that reads files of the working tree by
+// their path relative to the repository root
+func readWorkingTree(repoRoot string) func(path string) ([]byte, error) {
+	return func(path string) ([]byte, error) {
+		content, err := os.ReadFile(filepath.Join(repoRoot, path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return content, nil
+	}
+}
+
+// writePatch writes a patch to the given file, or to stdout for "-"
+func writePatch(dest, patch string, stdout io.Writer) error {
+	if dest == "-" {
+		_, err := io.WriteString(stdout, patch)
+		return err
+	}
+
+	if err := os.WriteFile(dest, []byte(patch), 0644); err != nil {
+		return fmt.Errorf("failed to write patch to %s: %w", dest, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// runStaged formats the changed lines of the content staged in the index and
+// writes the result back to the index. Working tree files are only updated when
+// they have no unstaged edits, so partially staged files keep their unstaged hunks.
+// Progress messages go to progress. It returns the number of files that would be
+// reformatted in dry-run mode.
+func runStaged(gitClient *git.Git, fmtr formatter.Formatter, dryRun, verbose bool, progress io.Writer) (int, error) {
+	if verbose {
+		fmt.Fprintln(progress, "Getting staged lines...")
+	}
+
+	fileChanges, err := gitClient.GetStagedLineRanges()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(fileChanges) == 0 {
+		fmt.Fprintln(progress, "No staged Python files with changed lines")
+		return 0, nil
+	}
+
+	if dryRun {
+		fmt.Fprintln(progress, "Checking staged changes in dry-run mode...")
+	} else {
+		fmt.Fprintf(progress, "Running %s on staged lines...\n", fmtr.Name())
+	}
+	fmt.Fprintln(progress)
+
+	wouldReformat := 0
+	for _, fc := range fileChanges {
+		original, err := gitClient.ReadIndexFile(fc.FilePath)
+		if err != nil {
+			return wouldReformat, err
+		}
+
+		formatted, err := fmtr.FormatContentByLineRanges(fc.FilePath, original, fc.LineRanges)
+		if err != nil {
+			return wouldReformat, err
+		}
+
+		if bytes.Equal(original, formatted) {
+			if verbose {
+				fmt.Fprintf(progress, "Already formatted: %s\n", fc.FilePath)
+			}
+			continue
+		}
+
+		if dryRun {
+			fmt.Fprintf(progress, "Would reformat: %s\n", fc.FilePath)
+			wouldReformat++
+			continue
+		}
+
+		if err := gitClient.WriteIndexFile(fc.FilePath, formatted); err != nil {
+			return wouldReformat, err
+		}
+
+		if err := syncWorkingTree(gitClient.GetRepoRoot(), fc.FilePath, original, formatted, progress); err != nil {
+			return wouldReformat, err
+		}
+
+		fmt.Fprintf(progress, "Formatted and re-staged: %s\n", fc.FilePath)
+	}
+
+	return wouldReformat, nil
+}
+
+// syncWorkingTree writes the formatted content to the working tree file when it
+// still matches the originally staged content. Files with unstaged edits are left alone.
+func syncWorkingTree(repoRoot, filePath string, original, formatted []byte, progress io.Writer) error {
+	absPath := filepath.Join(repoRoot, filePath)
+
+	current, err := os.ReadFile(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+
+	if !bytes.Equal(current, original) {
+		fmt.Fprintf(progress, "Note: %s has unstaged changes; only the staged content was formatted\n", filePath)
+		return nil
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filePath, err)
+	}
+
+	if err := os.WriteFile(absPath, formatted, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+
+	return nil
+}
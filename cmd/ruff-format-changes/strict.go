@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// Modes of --strict-ranges
+const (
+	strictRevert = "revert"
+	strictReport = "report"
+)
+
+// validateStrictRanges checks the --strict-ranges mode against the other options
+func validateStrictRanges(opts options) error {
+	switch opts.strictRanges {
+	case "":
+		return nil
+	case strictRevert, strictReport:
+	default:
+		return fmt.Errorf("invalid --strict-ranges mode %q (available: %s, %s)", opts.strictRanges, strictRevert, strictReport)
+	}
+
+	if opts.staged {
+		return fmt.Errorf("--strict-ranges is not supported with --staged")
+	}
+	if (opts.dryRun || opts.check) && opts.patch == "" {
+		return fmt.Errorf("--strict-ranges needs the formatted content; use it when formatting in place or with --patch")
+	}
+	return nil
+}
+
+// enforceStrictRanges compares every formatted file with its content in the
+// snapshot and records edits outside the changed ranges in the report. In revert
+// mode those edits are undone on disk.
+func enforceStrictRanges(repoRoot string, snapshot *formatter.Snapshot, fileChanges []git.FileChanges, report *formatter.Report, mode string) error {
+	ranges := make(map[string][]git.LineRange, len(fileChanges))
+	for _, fc := range fileChanges {
+		ranges[fc.FilePath] = append(ranges[fc.FilePath], fc.LineRanges...)
+	}
+
+	for i := range report.Files {
+		f := &report.Files[i]
+		original, ok := snapshot.Content(f.FilePath)
+		if !ok || f.RolledBack || f.BeforeHash == f.AfterHash {
+			continue
+		}
+
+		absPath := filepath.Join(repoRoot, f.FilePath)
+		formatted, err := os.ReadFile(absPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.FilePath, err)
+		}
+
+		enforced, outside := formatter.EnforceRanges(original, formatted, ranges[f.FilePath])
+		if len(outside) == 0 {
+			continue
+		}
+		f.OutOfRange = outside
+
+		if mode != strictRevert {
+			continue
+		}
+		if err := formatter.WriteFileAtomic(absPath, enforced); err != nil {
+			return err
+		}
+		f.OutOfRangeReverted = true
+		f.AfterHash = formatter.HashContent(enforced)
+	}
+
+	return nil
+}
@@ -2,9 +2,10 @@ module github.com/horiagug/ruff-format-changes
 
 go 1.21
 
-require github.com/spf13/cobra v1.10.1
-
 require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.9 // indirect
+	github.com/BurntSushi/toml v1.5.0
+	github.com/spf13/cobra v1.10.1
+	github.com/spf13/pflag v1.0.9
 )
+
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -0,0 +1,214 @@
+// Package config reads the project configuration of ruff-format-changes and
+// resolves it against command line flags and environment variables.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/toml"
+	"github.com/spf13/pflag"
+)
+
+// FileName is the standalone configuration file, which takes precedence over
+// the [tool.ruff-format-changes] table of pyproject.toml
+const FileName = ".ruff-format-changes.toml"
+
+// envPrefix prefixes the environment variables that override settings
+const envPrefix = "RUFF_FORMAT_CHANGES_"
+
+// Keys are the configurable settings. Each key names both the setting in a
+// configuration file and the command line flag it configures.
+var Keys = []string{"base", "formatter", "include", "exclude", "jobs", "output-format"}
+
+// Sources of an effective setting besides a configuration file
+const (
+	SourceFlag    = "flag"
+	SourceDefault = "default"
+)
+
+// Config is the configuration read from a project file
+type Config struct {
+	// Path is the file the configuration was read from, or "" if there is none
+	Path   string
+	values map[string]any
+}
+
+// Entry is the effective value of a setting and where it was taken from
+type Entry struct {
+	Key    string
+	Value  any
+	Source string
+}
+
+// Load reads the configuration of the project at repoRoot from FileName or,
+// if it doesn't exist, from pyproject.toml. A missing configuration is empty.
+func Load(repoRoot string) (*Config, error) {
+	cfg := &Config{values: map[string]any{}}
+
+	for _, name := range []string{FileName, "pyproject.toml"} {
+		path := filepath.Join(repoRoot, name)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		doc, err := toml.Parse(data)
+		if err != nil {
+			// Don't fail on a part of pyproject.toml that isn't ours
+			if name == "pyproject.toml" && !toml.MentionsTable(data, "tool", "ruff-format-changes") {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring %s, which has no [tool.ruff-format-changes] table and could not be parsed: %v\n", name, err)
+				continue
+			}
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+
+		values := doc
+		if name == "pyproject.toml" {
+			if values = toml.Table(doc, "tool", "ruff-format-changes"); values == nil {
+				continue
+			}
+		}
+
+		for key := range values {
+			if !isKey(key) {
+				return nil, fmt.Errorf("unknown setting %q in %s (available: %s)", key, name, strings.Join(Keys, ", "))
+			}
+		}
+
+		cfg.Path = path
+		cfg.values = values
+		break
+	}
+
+	return cfg, nil
+}
+
+// EnvVar returns the environment variable that overrides a setting, e.g.
+// RUFF_FORMAT_CHANGES_OUTPUT_FORMAT for output-format
+func EnvVar(key string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// Apply sets every setting whose flag was not given on the command line from
+// its environment variable or, failing that, from the configuration file, and
+// returns the effective value of every setting. A setting's flag is looked up in
+// each flag set in turn; settings without a flag are skipped. Lists in
+// environment variables are separated by commas.
+func (c *Config) Apply(getenv func(string) string, flagSets ...*pflag.FlagSet) ([]Entry, error) {
+	var entries []Entry
+	for _, key := range Keys {
+		flag := lookupFlag(key, flagSets)
+		if flag == nil {
+			continue
+		}
+
+		source := SourceDefault
+		switch env := getenv(EnvVar(key)); {
+		case flag.Changed:
+			source = SourceFlag
+		case env != "":
+			source = EnvVar(key)
+			if err := setFlag(flag, splitList(flag, env)); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", source, err)
+			}
+		case c.values[key] != nil:
+			source = filepath.Base(c.Path)
+			values, err := fileValues(c.values[key])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s in %s: %w", key, source, err)
+			}
+			if err := setFlag(flag, values); err != nil {
+				return nil, fmt.Errorf("invalid %s in %s: %w", key, source, err)
+			}
+		}
+
+		entries = append(entries, Entry{Key: key, Value: flagValue(flag), Source: source})
+	}
+	return entries, nil
+}
+
+// lookupFlag returns the flag of a setting from the first flag set that has it
+func lookupFlag(key string, flagSets []*pflag.FlagSet) *pflag.Flag {
+	for _, flags := range flagSets {
+		if flag := flags.Lookup(key); flag != nil {
+			return flag
+		}
+	}
+	return nil
+}
+
+// setFlag sets a flag from a list of values, which must hold a single value
+// unless the flag is a list
+func setFlag(flag *pflag.Flag, values []string) error {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		return slice.Replace(values)
+	}
+	if len(values) != 1 {
+		return fmt.Errorf("expected a single value, got %d", len(values))
+	}
+	return flag.Value.Set(values[0])
+}
+
+// splitList splits an environment variable into values
+func splitList(flag *pflag.Flag, value string) []string {
+	if _, ok := flag.Value.(pflag.SliceValue); !ok {
+		return []string{value}
+	}
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// fileValues converts a value of the configuration file to flag values
+func fileValues(value any) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case []any:
+		values, ok := toml.Strings(v)
+		if !ok {
+			return nil, fmt.Errorf("expected a list of strings")
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
+
+// flagValue returns the typed value of a flag: a string list, an int or a string
+func flagValue(flag *pflag.Flag) any {
+	if slice, ok := flag.Value.(pflag.SliceValue); ok {
+		return slice.GetSlice()
+	}
+	if flag.Value.Type() == "int" {
+		if n, err := strconv.Atoi(flag.Value.String()); err == nil {
+			return n
+		}
+	}
+	return flag.Value.String()
+}
+
+// isKey reports whether key is a configurable setting
+func isKey(key string) bool {
+	for _, k := range Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func writeConfig(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repoRoot := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repoRoot, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return repoRoot
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		path     string
+		expected map[string]any
+		wantErr  string
+	}{
+		{
+			name:     "no configuration",
+			files:    map[string]string{},
+			expected: map[string]any{},
+		},
+		{
+			name:     "pyproject without table",
+			files:    map[string]string{"pyproject.toml": "[tool.ruff]\nline-length = 100\n"},
+			expected: map[string]any{},
+		},
+		{
+			name:     "pyproject",
+			files:    map[string]string{"pyproject.toml": "[tool.ruff-format-changes]\nbase = \"develop\"\njobs = 2\n"},
+			path:     "pyproject.toml",
+			expected: map[string]any{"base": "develop", "jobs": int64(2)},
+		},
+		{
+			name: "standalone file takes precedence",
+			files: map[string]string{
+				FileName:         "formatter = \"black\"\n",
+				"pyproject.toml": "[tool.ruff-format-changes]\nbase = \"develop\"\n",
+			},
+			path:     FileName,
+			expected: map[string]any{"formatter": "black"},
+		},
+		{
+			name:    "unknown setting",
+			files:   map[string]string{FileName: "bsae = \"develop\"\n"},
+			wantErr: `unknown setting "bsae"`,
+		},
+		{
+			name:    "invalid TOML",
+			files:   map[string]string{FileName: "base = develop\n"},
+			wantErr: "failed to parse " + FileName,
+		},
+		{
+			name:     "unparsable pyproject without table",
+			files:    map[string]string{"pyproject.toml": "[project]\nname = broken\n"},
+			expected: map[string]any{},
+		},
+		{
+			name:    "unparsable pyproject with table",
+			files:   map[string]string{"pyproject.toml": "[tool.ruff-format-changes]\nbase = develop\n"},
+			wantErr: "failed to parse pyproject.toml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoRoot := writeConfig(t, tt.files)
+			cfg, err := Load(repoRoot)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			expectedPath := ""
+			if tt.path != "" {
+				expectedPath = filepath.Join(repoRoot, tt.path)
+			}
+			if cfg.Path != expectedPath {
+				t.Errorf("Expected path %q, got %q", expectedPath, cfg.Path)
+			}
+			if !reflect.DeepEqual(cfg.values, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, cfg.values)
+			}
+		})
+	}
+}
+
+func TestEnvVar(t *testing.T) {
+	if got := EnvVar("output-format"); got != "RUFF_FORMAT_CHANGES_OUTPUT_FORMAT" {
+		t.Errorf("EnvVar(output-format) = %q", got)
+	}
+}
+
+// newFlagSet returns flags like the command line's, with the given arguments parsed
+func newFlagSet(t *testing.T, args ...string) *pflag.FlagSet {
+	t.Helper()
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("base", "", "")
+	flags.String("formatter", "ruff", "")
+	flags.StringArray("include", nil, "")
+	flags.StringArray("exclude", nil, "")
+	flags.Int("jobs", 8, "")
+	if err := flags.Parse(args); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	return flags
+}
+
+func TestApplyPrecedence(t *testing.T) {
+	repoRoot := writeConfig(t, map[string]string{FileName: `base = "develop"
+formatter = "black"
+include = ["src/**"]
+exclude = ["migrations"]
+jobs = 2
+`})
+	cfg, err := Load(repoRoot)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	env := map[string]string{
+		"RUFF_FORMAT_CHANGES_FORMATTER": "yapf",
+		"RUFF_FORMAT_CHANGES_EXCLUDE":   "vendor/**, build",
+	}
+	flags := newFlagSet(t, "--base", "main")
+
+	entries, err := cfg.Apply(func(key string) string { return env[key] }, flags)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// output-format has no flag in this flag set, so it is skipped
+	expected := []Entry{
+		{Key: "base", Value: "main", Source: SourceFlag},
+		{Key: "formatter", Value: "yapf", Source: "RUFF_FORMAT_CHANGES_FORMATTER"},
+		{Key: "include", Value: []string{"src/**"}, Source: FileName},
+		{Key: "exclude", Value: []string{"vendor/**", "build"}, Source: "RUFF_FORMAT_CHANGES_EXCLUDE"},
+		{Key: "jobs", Value: 2, Source: FileName},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, entries)
+	}
+
+	if jobs, _ := flags.GetInt("jobs"); jobs != 2 {
+		t.Errorf("Expected jobs flag to be set to 2, got %d", jobs)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	entries, err := cfg.Apply(func(string) string { return "" }, newFlagSet(t))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Source != SourceDefault {
+			t.Errorf("Expected %s to come from the defaults, got %s", entry.Key, entry.Source)
+		}
+	}
+}
+
+func TestApplyInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "env jobs", env: map[string]string{"RUFF_FORMAT_CHANGES_JOBS": "many"}, wantErr: "invalid RUFF_FORMAT_CHANGES_JOBS"},
+		{name: "file jobs", file: "jobs = \"many\"\n", wantErr: "invalid jobs in " + FileName},
+		{name: "list for a single value", file: "base = [\"main\", \"develop\"]\n", wantErr: "expected a single value"},
+		{name: "list of numbers", file: "include = [1, 2]\n", wantErr: "expected a list of strings"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, map[string]string{FileName: tt.file}))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			_, err = cfg.Apply(func(key string) string { return tt.env[key] }, newFlagSet(t))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Edit is a region where two texts differ, as half-open, zero-based line
+// index ranges: lines a[OldStart:OldEnd] were replaced by b[NewStart:NewEnd]
+type Edit struct {
+	OldStart int
+	OldEnd   int
+	NewStart int
+	NewEnd   int
+}
+
+// SplitLines splits text into lines, keeping the line endings
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the edits that turn a into b, in ascending order
+func Lines(a, b []string) []Edit {
+	// Strip the common prefix and suffix, which is most of the file for formatter diffs
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for i := range edits {
+		edits[i].OldStart += prefix
+		edits[i].OldEnd += prefix
+		edits[i].NewStart += prefix
+		edits[i].NewEnd += prefix
+	}
+	return edits
+}
+
+// maxCost bounds the edit distance a single bisection searches for. Past it,
+// the search splits at the furthest point it reached, which keeps diffs of files
+// that change completely from taking quadratic time at the price of a possibly
+// longer edit script.
+const maxCost = 4096
+
+// myers computes a shortest edit script with the linear-space, divide and
+// conquer variant of Myers' O(ND) algorithm and returns it as merged edit regions
+func myers(a, b []string) []Edit {
+	// keptA[i] reports whether a[i] is part of the common subsequence; keptB likewise for b
+	keptA := make([]bool, len(a))
+	keptB := make([]bool, len(b))
+	compare(a, b, 0, len(a), 0, len(b), keptA, keptB)
+
+	var edits []Edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		if i < len(a) && j < len(b) && keptA[i] && keptB[j] {
+			i++
+			j++
+			continue
+		}
+		e := Edit{OldStart: i, NewStart: j}
+		for i < len(a) && !keptA[i] {
+			i++
+		}
+		for j < len(b) && !keptB[j] {
+			j++
+		}
+		e.OldEnd, e.NewEnd = i, j
+		edits = append(edits, e)
+	}
+
+	return edits
+}
+
+// compare marks the lines of a[aLo:aHi] and b[bLo:bHi] that are part of a longest
+// common subsequence, splitting the regions where the searches from both ends meet
+func compare(a, b []string, aLo, aHi, bLo, bHi int, keptA, keptB []bool) {
+	for aLo < aHi && bLo < bHi && a[aLo] == b[bLo] {
+		keptA[aLo], keptB[bLo] = true, true
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && a[aHi-1] == b[bHi-1] {
+		aHi--
+		bHi--
+		keptA[aHi], keptB[bHi] = true, true
+	}
+	if aLo == aHi || bLo == bHi {
+		return
+	}
+
+	x, y, ok := bisect(a[aLo:aHi], b[bLo:bHi])
+	if !ok {
+		// No split makes progress: leave the whole region as one replacement
+		return
+	}
+	compare(a, b, aLo, aLo+x, bLo, bLo+y, keptA, keptB)
+	compare(a, b, aLo+x, aHi, bLo+y, bHi, keptA, keptB)
+}
+
+// bisect finds where the forward and reverse searches for a shortest edit path
+// from a to b meet, and returns that point as a split of both sequences. If they
+// do not meet within maxCost edits, it returns the furthest point the forward
+// search reached instead. It reports false if the split would not make the
+// problem smaller.
+func bisect(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := min((n+m+1)/2, maxCost)
+	offset := maxD
+	// vf[offset+k] is the furthest x reached on diagonal k = x-y going forward
+	// from the start; vr likewise going backward from the end, counted from the end
+	vf := make([]int, 2*maxD+1)
+	vr := make([]int, 2*maxD+1)
+	for i := range vf {
+		vf[i], vr[i] = -1, -1
+	}
+	vf[offset+1], vr[offset+1] = 0, 0
+
+	delta := n - m
+	// With an odd delta the paths overlap during a forward step, otherwise during a reverse one
+	odd := delta%2 != 0
+	// Diagonals that ran off the grid are not explored again
+	fStart, fEnd, rStart, rEnd := 0, 0, 0, 0
+	bestX, bestY := 0, 0
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			if x <= n && y <= m && x+y > bestX+bestY {
+				bestX, bestY = x, y
+			}
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				if rk := offset + delta - k; rk >= 0 && rk < len(vr) && vr[rk] != -1 && x >= n-vr[rk] {
+					return split(x, y, n, m)
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vr[offset+k-1] < vr[offset+k+1]) {
+				x = vr[offset+k+1]
+			} else {
+				x = vr[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			vr[offset+k] = x
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd:
+				if fk := offset + delta - k; fk >= 0 && fk < len(vf) && vf[fk] != -1 && vf[fk] >= n-x {
+					return split(vf[fk], vf[fk]-(fk-offset), n, m)
+				}
+			}
+		}
+	}
+
+	return split(bestX, bestY, n, m)
+}
+
+// split returns x and y as a split point of an n by m problem, unless it would
+// leave one of the halves as large as the whole
+func split(x, y, n, m int) (int, int, bool) {
+	if (x == 0 && y == 0) || (x == n && y == m) {
+		return 0, 0, false
+	}
+	return x, y, true
+}
+
+// Unified returns a unified diff between old and new with the given number of
+// context lines, or an empty string if they are equal. The header names the
+// files a/path and b/path, as git does, so the result can be applied with git apply.
+func Unified(path, old, new string, context int) string {
+	a, b := SplitLines(old), SplitLines(new)
+	edits := Lines(a, b)
+	if len(edits) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "diff --git a/%s b/%s\n", path, path)
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", path, path)
+
+	for start := 0; start < len(edits); {
+		// Group edits whose context would overlap into one hunk
+		end := start + 1
+		for end < len(edits) && edits[end].OldStart-edits[end-1].OldEnd <= 2*context {
+			end++
+		}
+
+		first, last := edits[start], edits[end-1]
+		oldStart := max(first.OldStart-context, 0)
+		oldEnd := min(last.OldEnd+context, len(a))
+		newStart := first.NewStart - (first.OldStart - oldStart)
+		newEnd := last.NewEnd + (oldEnd - last.OldEnd)
+
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(oldStart, oldEnd-oldStart), hunkRange(newStart, newEnd-newStart))
+
+		i := oldStart
+		for _, e := range edits[start:end] {
+			for ; i < e.OldStart; i++ {
+				writeLine(&sb, ' ', a[i])
+			}
+			for _, line := range a[e.OldStart:e.OldEnd] {
+				writeLine(&sb, '-', line)
+			}
+			for _, line := range b[e.NewStart:e.NewEnd] {
+				writeLine(&sb, '+', line)
+			}
+			i = e.OldEnd
+		}
+		for ; i < oldEnd; i++ {
+			writeLine(&sb, ' ', a[i])
+		}
+
+		start = end
+	}
+
+	return sb.String()
+}
+
+// hunkRange formats the start,count part of a hunk header
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// An empty range names the line before it
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// writeLine writes a diff line, marking a missing final newline as git does
+func writeLine(sb *strings.Builder, prefix byte, line string) {
+	sb.WriteByte(prefix)
+	sb.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		sb.WriteString("\n\\ No newline at end of file\n")
+	}
+}
+
+// ParseUnified returns the edits of a unified diff of a single file, such as the
+// one a formatter prints with --diff. Lines outside of hunks, like the file
+// headers, are ignored. It is the inverse of Unified.
+func ParseUnified(text string) ([]Edit, error) {
+	var edits []Edit
+	var current *Edit
+	flush := func() {
+		if current != nil {
+			edits = append(edits, *current)
+			current = nil
+		}
+	}
+
+	oldLine, newLine, oldLeft, newLeft := 0, 0, 0, 0
+	for _, line := range strings.Split(text, "\n") {
+		if oldLeft <= 0 && newLeft <= 0 {
+			flush()
+			if !strings.HasPrefix(line, "@@ ") {
+				continue
+			}
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid hunk header %q", line)
+			}
+			var err error
+			if oldLine, oldLeft, err = parseHunkRange(fields[1], '-'); err != nil {
+				return nil, fmt.Errorf("invalid hunk header %q: %w", line, err)
+			}
+			if newLine, newLeft, err = parseHunkRange(fields[2], '+'); err != nil {
+				return nil, fmt.Errorf("invalid hunk header %q: %w", line, err)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "\\"):
+			// No newline at end of file
+		case strings.HasPrefix(line, "-"):
+			if current == nil {
+				current = &Edit{OldStart: oldLine, OldEnd: oldLine, NewStart: newLine, NewEnd: newLine}
+			}
+			current.OldEnd++
+			oldLine++
+			oldLeft--
+		case strings.HasPrefix(line, "+"):
+			if current == nil {
+				current = &Edit{OldStart: oldLine, OldEnd: oldLine, NewStart: newLine, NewEnd: newLine}
+			}
+			current.NewEnd++
+			newLine++
+			newLeft--
+		default:
+			// Context; some tools strip the space of empty context lines
+			flush()
+			oldLine++
+			newLine++
+			oldLeft--
+			newLeft--
+		}
+	}
+	flush()
+
+	return edits, nil
+}
+
+// parseHunkRange parses the start,count part of a hunk header into the zero-based
+// index of its first line and its number of lines
+func parseHunkRange(field string, prefix byte) (int, int, error) {
+	if len(field) < 2 || field[0] != prefix {
+		return 0, 0, fmt.Errorf("expected %c range, got %q", prefix, field)
+	}
+	start, count := 0, 1
+	if _, err := fmt.Sscanf(field[1:], "%d,%d", &start, &count); err != nil {
+		if _, err := fmt.Sscanf(field[1:], "%d", &start); err != nil {
+			return 0, 0, err
+		}
+		count = 1
+	}
+	if count == 0 {
+		// An empty range names the line before it
+		return start, 0, nil
+	}
+	return start - 1, count, nil
+}
@@ -0,0 +1,297 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// applyEdits rebuilds b from a and the edits returned by Lines
+func applyEdits(a, b []string, edits []Edit) []string {
+	var result []string
+	i := 0
+	for _, e := range edits {
+		result = append(result, a[i:e.OldStart]...)
+		result = append(result, b[e.NewStart:e.NewEnd]...)
+		i = e.OldEnd
+	}
+	return append(result, a[i:]...)
+}
+
+// lcsLength returns the length of a longest common subsequence of a and b
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// changedLines counts the lines the edits remove and add
+func changedLines(edits []Edit) int {
+	total := 0
+	for _, e := range edits {
+		total += e.OldEnd - e.OldStart + e.NewEnd - e.NewStart
+	}
+	return total
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"", nil},
+		{"a\n", []string{"a\n"}},
+		{"a\nb", []string{"a\n", "b"}},
+		{"a\n\nb\n", []string{"a\n", "\n", "b\n"}},
+	}
+
+	for _, tt := range tests {
+		got := SplitLines(tt.text)
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") || len(got) != len(tt.expected) {
+			t.Errorf("SplitLines(%q) = %q, want %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestLinesSimple(t *testing.T) {
+	a := SplitLines("a\nb\nc\nd\n")
+	b := SplitLines("a\nB\nc\nd\ne\n")
+
+	edits := Lines(a, b)
+	expected := []Edit{
+		{OldStart: 1, OldEnd: 2, NewStart: 1, NewEnd: 2},
+		{OldStart: 4, OldEnd: 4, NewStart: 4, NewEnd: 5},
+	}
+
+	if len(edits) != len(expected) {
+		t.Fatalf("Expected %d edits, got %v", len(expected), edits)
+	}
+	for i := range expected {
+		if edits[i] != expected[i] {
+			t.Errorf("Edit %d: expected %+v, got %+v", i, expected[i], edits[i])
+		}
+	}
+}
+
+func TestLinesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []string{"a\n", "b\n", "c\n", "d\n"}
+
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(40))
+		for i := range lines {
+			lines[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		edits := Lines(a, b)
+
+		got := applyEdits(a, b, edits)
+		if strings.Join(got, "") != strings.Join(b, "") {
+			t.Fatalf("Edits %v do not turn %q into %q", edits, a, b)
+		}
+		for j := 1; j < len(edits); j++ {
+			if edits[j].OldStart <= edits[j-1].OldEnd && edits[j].NewStart <= edits[j-1].NewEnd {
+				t.Fatalf("Edits %v are not separated by kept lines", edits)
+			}
+		}
+		if got, want := changedLines(edits), len(a)+len(b)-2*lcsLength(a, b); got != want {
+			t.Fatalf("Edits %v of %q into %q change %d lines, want %d", edits, a, b, got, want)
+		}
+	}
+}
+
+func TestLinesCompleteChange(t *testing.T) {
+	a := make([]string, 12000)
+	b := make([]string, 12000)
+	for i := range a {
+		a[i] = fmt.Sprintf("old %d\n", i)
+		b[i] = fmt.Sprintf("new %d\n", i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := Lines(a, b)
+	runtime.ReadMemStats(&after)
+
+	expected := []Edit{{OldStart: 0, OldEnd: len(a), NewStart: 0, NewEnd: len(b)}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Fatalf("Expected one replacement %v, got %d edits", expected, len(edits))
+	}
+	// Memory must stay linear in the input, not grow with the edit distance
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("Diffing %d lines allocated %d bytes", len(a)+len(b), allocated)
+	}
+}
+
+func TestLinesLargeScatteredChange(t *testing.T) {
+	// Every third line changes, so the edit distance is well past maxCost
+	a := make([]string, 15000)
+	b := make([]string, 15000)
+	changed := 0
+	for i := range a {
+		a[i] = fmt.Sprintf("line %d\n", i)
+		b[i] = a[i]
+		if i%3 == 0 {
+			b[i] = fmt.Sprintf("LINE %d\n", i)
+			changed++
+		}
+	}
+
+	edits := Lines(a, b)
+	got := applyEdits(a, b, edits)
+	if strings.Join(got, "") != strings.Join(b, "") {
+		t.Fatalf("Edits do not turn a into b")
+	}
+	if len(edits) != changed || changedLines(edits) != 2*changed {
+		t.Errorf("Expected %d single-line replacements, got %d edits changing %d lines", changed, len(edits), changedLines(edits))
+	}
+}
+
+func TestUnifiedEqual(t *testing.T) {
+	if got := Unified("main.py", "a\n", "a\n", 3); got != "" {
+		t.Errorf("Expected empty diff for equal content, got %q", got)
+	}
+}
+
+func TestUnifiedFormat(t *testing.T) {
+	old := "import os\nx=1\ny = 2\n"
+	new := "import os\nx = 1\ny = 2\n"
+
+	expected := "diff --git a/pkg/main.py b/pkg/main.py\n" +
+		"--- a/pkg/main.py\n" +
+		"+++ b/pkg/main.py\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" import os\n" +
+		"-x=1\n" +
+		"+x = 1\n" +
+		" y = 2\n"
+
+	if got := Unified("pkg/main.py", old, new, 3); got != expected {
+		t.Errorf("Unexpected diff:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestUnifiedNoNewlineAtEnd(t *testing.T) {
+	got := Unified("main.py", "x=1", "x = 1\n", 3)
+	if !strings.Contains(got, "-x=1\n\\ No newline at end of file\n+x = 1\n") {
+		t.Errorf("Expected missing newline marker, got:\n%s", got)
+	}
+}
+
+func TestUnifiedAppliesWithGit(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var oldLines, newLines []string
+	for i := 0; i < 40; i++ {
+		line := "x = " + strings.Repeat("1", i%5+1) + "\n"
+		oldLines = append(oldLines, line)
+		switch {
+		case i == 3:
+			newLines = append(newLines, "x=3\n", "extra = True\n")
+		case i == 20:
+			// deleted
+		case i == 35:
+			newLines = append(newLines, "y = 35\n")
+		default:
+			newLines = append(newLines, line)
+		}
+	}
+	old, new := strings.Join(oldLines, ""), strings.Join(newLines, "")
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte(old), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	patch := Unified("main.py", old, new, 3)
+	if strings.Count(patch, "@@ -") != 3 {
+		t.Errorf("Expected 3 hunks, got:\n%s", patch)
+	}
+
+	cmd := exec.Command("git", "apply", "-")
+	cmd.Dir = tmpDir
+	cmd.Stdin = strings.NewReader(patch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git apply failed: %v: %s\npatch:\n%s", err, output, patch)
+	}
+
+	applied, err := os.ReadFile(filepath.Join(tmpDir, "main.py"))
+	if err != nil {
+		t.Fatalf("Failed to read patched file: %v", err)
+	}
+	if string(applied) != new {
+		t.Errorf("Patched file does not match:\n%s", applied)
+	}
+}
+
+func TestParseUnifiedRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []string{"a\n", "b\n", "c\n", "d\n"}
+
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(40))
+		for i := range lines {
+			lines[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		for _, context := range []int{0, 3} {
+			expected := Lines(a, b)
+			got, err := ParseUnified(Unified("main.py", strings.Join(a, ""), strings.Join(b, ""), context))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(got) != len(expected) || len(got) > 0 && !reflect.DeepEqual(got, expected) {
+				t.Fatalf("Context %d: expected edits %v, got %v", context, expected, got)
+			}
+		}
+	}
+}
+
+func TestParseUnifiedFormatterOutput(t *testing.T) {
+	// black --diff puts timestamps in the file headers
+	output := "--- main.py\t2024-01-01 00:00:00+00:00\n" +
+		"+++ main.py\t2024-01-01 00:00:01+00:00\n" +
+		"@@ -1,4 +1,5 @@\n" +
+		" import os\n" +
+		"-x=1\n" +
+		"+x = 1\n" +
+		"+\n" +
+		" \n" +
+		" y = 2\n"
+
+	edits, err := ParseUnified(output)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []Edit{{OldStart: 1, OldEnd: 2, NewStart: 1, NewEnd: 3}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("Expected %v, got %v", expected, edits)
+	}
+
+	if _, err := ParseUnified("@@ -x +1 @@\n"); err == nil {
+		t.Error("Expected error for invalid hunk header, got nil")
+	}
+}
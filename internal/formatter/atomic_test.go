@@ -0,0 +1,39 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.py")
+	if err := os.WriteFile(path, []byte("x=1\n"), 0755); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	if err := WriteFileAtomic(path, []byte("x = 1\n")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "x = 1\n" {
+		t.Errorf("Unexpected content %q", content)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0755 {
+		t.Errorf("Expected permissions to be kept, got %v", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files, got %v", entries)
+	}
+}
+
+func TestWriteFileAtomicMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "main.py")
+	if err := WriteFileAtomic(path, []byte("x\n")); err == nil {
+		t.Errorf("Expected error for a missing directory, got nil")
+	}
+}
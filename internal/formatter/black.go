@@ -0,0 +1,29 @@
+package formatter
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// NewBlack creates a formatter backed by black, which formats ranges with --line-ranges
+func NewBlack(repoRoot string, dryRun, verbose bool) Formatter {
+	return &commandFormatter{
+		name:     "black",
+		install:  "pip install black",
+		repoRoot: repoRoot,
+		dryRun:   dryRun,
+		verbose:  verbose,
+		jobs:     runtime.NumCPU(),
+		log:      os.Stdout,
+		rangeArgs: func(ranges []git.LineRange) []string {
+			return lineRangeArgs("--line-ranges", ranges)
+		},
+		checkArgs: []string{"--check", "--diff"},
+		stdinArgs: func(filePath string) []string {
+			return []string{"--quiet", "--stdin-filename", filePath, "-"}
+		},
+		changedExitCode: 1,
+	}
+}
@@ -0,0 +1,246 @@
+package formatter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/horiagug/ruff-format-changes/internal/diff"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// Formatter formats only the changed line ranges of Python files
+type Formatter interface {
+	// Name returns the name of the formatter, e.g. "ruff"
+	Name() string
+	// CheckInstalled verifies that the formatter is installed and accessible
+	CheckInstalled() error
+	// SetJobs sets how many files FormatFilesByLineRanges formats in parallel
+	SetJobs(jobs int)
+	// SetLog sets where progress messages are written. The default is stdout.
+	SetLog(log io.Writer)
+	// FormatFilesByLineRanges formats the given line ranges of files on disk and
+	// reports the outcome of every range. An error is returned if any range failed.
+	FormatFilesByLineRanges(fileChanges []git.FileChanges) (*Report, error)
+	// FormatContentByLineRanges formats content in memory as if it were stored
+	// at filePath and returns the result without writing anything to disk
+	FormatContentByLineRanges(filePath string, content []byte, ranges []git.LineRange) ([]byte, error)
+}
+
+// commandFormatter runs an external formatter that accepts every line range of
+// a file in a single invocation. The backends differ only in their arguments.
+type commandFormatter struct {
+	name     string
+	install  string
+	repoRoot string
+	dryRun   bool
+	verbose  bool
+	jobs     int
+	log      io.Writer
+
+	// rangeArgs encodes the line ranges of a file as command line arguments
+	rangeArgs func(ranges []git.LineRange) []string
+	// writeArgs are passed when formatting files in place
+	writeArgs []string
+	// checkArgs are passed in dry-run mode to print a diff instead of writing
+	checkArgs []string
+	// stdinArgs makes the formatter read stdin and write the result to stdout
+	stdinArgs func(filePath string) []string
+	// changedExitCode is the exit code reporting that files would be reformatted in dry-run mode
+	changedExitCode int
+}
+
+// Name returns the name of the formatter
+func (c *commandFormatter) Name() string {
+	return c.name
+}
+
+// CheckInstalled verifies that the formatter executable is accessible
+func (c *commandFormatter) CheckInstalled() error {
+	if _, err := exec.LookPath(c.name); err != nil {
+		return fmt.Errorf("%s not found. Please install it with: %s", c.name, c.install)
+	}
+	return nil
+}
+
+// SetJobs sets how many files are formatted in parallel
+func (c *commandFormatter) SetJobs(jobs int) {
+	c.jobs = jobs
+}
+
+// SetLog sets where progress messages are written
+func (c *commandFormatter) SetLog(log io.Writer) {
+	c.log = log
+}
+
+// FormatFilesByLineRanges runs the formatter once per file with all of its ranges,
+// formatting up to jobs files in parallel
+func (c *commandFormatter) FormatFilesByLineRanges(fileChanges []git.FileChanges) (*Report, error) {
+	report := &Report{Formatter: c.name, DryRun: c.dryRun, Files: []FileResult{}}
+	started := time.Now()
+
+	if len(fileChanges) == 0 {
+		if c.verbose {
+			fmt.Fprintln(c.log, "No changed lines to format")
+		}
+		return report, nil
+	}
+
+	report.Files = FormatFiles(fileChanges, c.jobs, c.log, c.formatFile)
+
+	report.Duration = time.Since(started)
+	return report, FailureError(report)
+}
+
+// formatFile runs the formatter on a single file and records the outcome for each
+// of its ranges. Log messages are written to log.
+func (c *commandFormatter) formatFile(fc git.FileChanges, log io.Writer) FileResult {
+	absPath := filepath.Join(c.repoRoot, fc.FilePath)
+	result := FileResult{FilePath: fc.FilePath, BeforeHash: HashFile(absPath)}
+	started := time.Now()
+
+	// Kept to find the ranges an in-place run changed
+	var original []byte
+	if !c.dryRun {
+		original, _ = os.ReadFile(absPath)
+	}
+
+	args := c.rangeArgs(fc.LineRanges)
+	if c.dryRun {
+		args = append(args, c.checkArgs...)
+	} else {
+		args = append(args, c.writeArgs...)
+	}
+	args = append(args, absPath)
+
+	if c.verbose {
+		fmt.Fprintf(log, "Running: %s %s\n", c.name, strings.Join(args, " "))
+	}
+
+	cmd := exec.Command(c.name, args...)
+	cmd.Dir = c.repoRoot
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	result.AfterHash = HashFile(absPath)
+	result.Duration = time.Since(started)
+
+	var status Status
+	var errMsg string
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil && c.dryRun:
+		status = StatusUnchanged
+		// Formatters like yapf may exit 0 even when printing a diff
+		if stdout.Len() > 0 {
+			status = StatusWouldReformat
+		}
+	case err == nil && result.AfterHash != result.BeforeHash:
+		status = StatusFormatted
+	case err == nil:
+		status = StatusUnchanged
+	case c.dryRun && errors.As(err, &exitErr) && exitErr.ExitCode() == c.changedExitCode:
+		status = StatusWouldReformat
+	default:
+		status = StatusFailed
+		errMsg = fmt.Sprintf("%s failed: %v", c.name, err)
+	}
+
+	if c.dryRun && status == StatusWouldReformat {
+		result.Diff = stdout.String()
+	}
+
+	// The formatter reports on the whole file, so its edits are mapped back to
+	// the ranges they touch
+	statuses := make([]Status, len(fc.LineRanges))
+	switch status {
+	case StatusWouldReformat:
+		edits, _ := diff.ParseUnified(result.Diff)
+		statuses = RangeStatuses(edits, fc.LineRanges, status)
+	case StatusFormatted:
+		formatted, _ := os.ReadFile(absPath)
+		statuses = ContentRangeStatuses(original, formatted, fc.LineRanges, status)
+	default:
+		for i := range statuses {
+			statuses[i] = status
+		}
+	}
+
+	for i, lr := range fc.LineRanges {
+		result.Ranges = append(result.Ranges, RangeResult{
+			Range:    lr,
+			Status:   statuses[i],
+			Stderr:   stderr.String(),
+			Error:    errMsg,
+			Duration: result.Duration,
+		})
+	}
+
+	return result
+}
+
+// FormatContentByLineRanges pipes content through the formatter in memory
+func (c *commandFormatter) FormatContentByLineRanges(filePath string, content []byte, ranges []git.LineRange) ([]byte, error) {
+	args := append(c.rangeArgs(ranges), c.stdinArgs(filePath)...)
+
+	if c.verbose {
+		fmt.Fprintf(c.log, "Running: %s %s\n", c.name, strings.Join(args, " "))
+	}
+
+	cmd := exec.Command(c.name, args...)
+	cmd.Dir = c.repoRoot
+	cmd.Stdin = bytes.NewReader(content)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s failed for %s: %w: %s", c.name, filePath, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// FailureError returns an error listing the first failure of every failed file
+// of a report, or nil if every range was formatted successfully
+func FailureError(report *Report) error {
+	failed := report.CountFiles(StatusFailed)
+	if failed == 0 {
+		return nil
+	}
+
+	var failures []string
+	for _, f := range report.Files {
+		for _, rr := range f.Ranges {
+			if rr.Status == StatusFailed {
+				failures = append(failures, fmt.Sprintf("%s: %s", f.FilePath, rr.Error))
+				break
+			}
+		}
+	}
+
+	if len(failures) == 1 {
+		return fmt.Errorf("%s failed on 1 file(s): %s", report.Formatter, failures[0])
+	}
+	return fmt.Errorf("%s failed on %d file(s):\n  %s", report.Formatter, failed, strings.Join(failures, "\n  "))
+}
+
+// lineRangeArgs encodes each range as flag followed by START-END
+func lineRangeArgs(flag string, ranges []git.LineRange) []string {
+	var args []string
+	for _, lr := range ranges {
+		args = append(args, flag, fmt.Sprintf("%d-%d", lr.Start, lr.End))
+	}
+	return args
+}
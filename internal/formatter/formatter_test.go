@@ -0,0 +1,173 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/testutil"
+)
+
+// argsLogger is a fake tool script that appends its arguments to a log file
+func argsLogger(logFile string) string {
+	return `echo "$@" >> ` + logFile + "\n"
+}
+
+func TestFormatFilesByLineRangesArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		newFunc  func(repoRoot string, dryRun, verbose bool) Formatter
+		dryRun   bool
+		expected string
+	}{
+		{"black", NewBlack, false, "--line-ranges 1-2 --line-ranges 10-10 REPO/main.py"},
+		{"black", NewBlack, true, "--line-ranges 1-2 --line-ranges 10-10 --check --diff REPO/main.py"},
+		{"yapf", NewYapf, false, "--lines 1-2 --lines 10-10 --in-place REPO/main.py"},
+		{"yapf", NewYapf, true, "--lines 1-2 --lines 10-10 --diff REPO/main.py"},
+		{"isort", NewIsort, false, "REPO/main.py"},
+		{"isort", NewIsort, true, "--check-only --diff REPO/main.py"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoRoot := t.TempDir()
+			logFile := filepath.Join(t.TempDir(), "args.log")
+			testutil.InstallFakeTool(t, tt.name, argsLogger(logFile))
+
+			f := tt.newFunc(repoRoot, tt.dryRun, false)
+			if f.Name() != tt.name {
+				t.Errorf("Expected name %s, got %s", tt.name, f.Name())
+			}
+
+			fileChanges := []git.FileChanges{
+				{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 2}, {Start: 10, End: 10}}},
+			}
+			if _, err := f.FormatFilesByLineRanges(fileChanges); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			logged, err := os.ReadFile(logFile)
+			if err != nil {
+				t.Fatalf("Failed to read args log: %v", err)
+			}
+			expected := strings.ReplaceAll(tt.expected, "REPO", repoRoot)
+			if strings.TrimSpace(string(logged)) != expected {
+				t.Errorf("Expected args %q, got %q", expected, strings.TrimSpace(string(logged)))
+			}
+		})
+	}
+}
+
+func TestFormatFilesByLineRangesDryRunChangedExitCode(t *testing.T) {
+	testutil.InstallFakeTool(t, "black", "echo 'would reformat main.py'; exit 1\n")
+
+	f := NewBlack(t.TempDir(), true, false)
+	fileChanges := []git.FileChanges{{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}}}}
+
+	if _, err := f.FormatFilesByLineRanges(fileChanges); err != nil {
+		t.Errorf("Expected 'would reformat' exit code to be accepted in dry-run mode, got %v", err)
+	}
+}
+
+func TestFormatFilesByLineRangesError(t *testing.T) {
+	testutil.InstallFakeTool(t, "black", "echo 'error: cannot format main.py' >&2; exit 123\n")
+
+	f := NewBlack(t.TempDir(), true, false)
+	fileChanges := []git.FileChanges{{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}}}}
+
+	if _, err := f.FormatFilesByLineRanges(fileChanges); err == nil {
+		t.Errorf("Expected error for internal formatter failure, got nil")
+	}
+}
+
+func TestFormatContentByLineRanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		newFunc  func(repoRoot string, dryRun, verbose bool) Formatter
+		expected string
+	}{
+		{"black", NewBlack, "--line-ranges 3-4 --quiet --stdin-filename pkg/main.py -"},
+		{"yapf", NewYapf, "--lines 3-4"},
+		{"isort", NewIsort, "--filename pkg/main.py -"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The fake tool echoes stdin followed by its arguments
+			testutil.InstallFakeTool(t, tt.name, "cat; echo \"$@\"\n")
+
+			f := tt.newFunc(t.TempDir(), false, false)
+			result, err := f.FormatContentByLineRanges("pkg/main.py", []byte("x = 1\n"), []git.LineRange{{Start: 3, End: 4}})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			expected := "x = 1\n" + tt.expected + "\n"
+			if string(result) != expected {
+				t.Errorf("Expected %q, got %q", expected, result)
+			}
+		})
+	}
+}
+
+func TestCheckInstalledMissing(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := NewYapf("/tmp/repo", false, false).CheckInstalled()
+	if err == nil {
+		t.Fatalf("Expected error when yapf is missing, got nil")
+	}
+	if !strings.Contains(err.Error(), "pip install yapf") {
+		t.Errorf("Expected install hint in error, got %v", err)
+	}
+}
+
+func TestFormatFilesByLineRangesRangeStatuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		newFunc func(repoRoot string, dryRun, verbose bool) Formatter
+		dryRun  bool
+		script  string
+		changed Status
+	}{
+		{
+			name:    "black",
+			newFunc: NewBlack,
+			dryRun:  true,
+			script:  "printf -- '--- main.py\\n+++ main.py\\n@@ -10 +10 @@\\n-y=2\\n+y = 2\\n'; exit 1\n",
+			changed: StatusWouldReformat,
+		},
+		{
+			name:    "yapf",
+			newFunc: NewYapf,
+			script:  "for file; do :; done; sed -i 's/y=2/y = 2/' \"$file\"\n",
+			changed: StatusFormatted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoRoot := t.TempDir()
+			content := "x=1\n" + strings.Repeat("\n", 8) + "y=2\n"
+			if err := os.WriteFile(filepath.Join(repoRoot, "main.py"), []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			testutil.InstallFakeTool(t, tt.name, tt.script)
+
+			f := tt.newFunc(repoRoot, tt.dryRun, false)
+			report, err := f.FormatFilesByLineRanges([]git.FileChanges{
+				{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}, {Start: 10, End: 10}}},
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			ranges := report.Files[0].Ranges
+			if len(ranges) != 2 || ranges[0].Status != StatusUnchanged || ranges[1].Status != tt.changed {
+				t.Errorf("Expected only the second range to be %s, got %+v", tt.changed, ranges)
+			}
+		})
+	}
+}
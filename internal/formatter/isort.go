@@ -0,0 +1,30 @@
+package formatter
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// NewIsort creates a formatter backed by isort. isort cannot restrict itself to
+// line ranges, so it sorts the imports of every file that has changed lines.
+func NewIsort(repoRoot string, dryRun, verbose bool) Formatter {
+	return &commandFormatter{
+		name:     "isort",
+		install:  "pip install isort",
+		repoRoot: repoRoot,
+		dryRun:   dryRun,
+		verbose:  verbose,
+		jobs:     runtime.NumCPU(),
+		log:      os.Stdout,
+		rangeArgs: func(ranges []git.LineRange) []string {
+			return nil
+		},
+		checkArgs: []string{"--check-only", "--diff"},
+		stdinArgs: func(filePath string) []string {
+			return []string{"--filename", filePath, "-"}
+		},
+		changedExitCode: 1,
+	}
+}
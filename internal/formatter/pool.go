@@ -0,0 +1,58 @@
+package formatter
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// FormatFiles formats files on a pool of up to jobs workers. All ranges of a file
+// are handled by the same call to format, so they stay serialized. Each file logs
+// to its own buffer, which is copied to log in the order of fileChanges once the
+// file and all files before it are done, and results are returned in that same
+// order, so output is deterministic regardless of which file finishes first.
+func FormatFiles(fileChanges []git.FileChanges, jobs int, log io.Writer, format func(fc git.FileChanges, log io.Writer) FileResult) []FileResult {
+	n := len(fileChanges)
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > n {
+		jobs = n
+	}
+
+	results := make([]FileResult, n)
+	logs := make([]bytes.Buffer, n)
+
+	next := make(chan int)
+	done := make(chan int)
+
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range next {
+				results[i] = format(fileChanges[i], &logs[i])
+				done <- i
+			}
+		}()
+	}
+
+	go func() {
+		for i := range fileChanges {
+			next <- i
+		}
+		close(next)
+	}()
+
+	finished := make([]bool, n)
+	flushed := 0
+	for range fileChanges {
+		finished[<-done] = true
+		for flushed < n && finished[flushed] {
+			log.Write(logs[flushed].Bytes())
+			logs[flushed] = bytes.Buffer{}
+			flushed++
+		}
+	}
+
+	return results
+}
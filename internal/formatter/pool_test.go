@@ -0,0 +1,85 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+func TestFormatFilesKeepsOrder(t *testing.T) {
+	var fileChanges []git.FileChanges
+	delays := make(map[string]time.Duration)
+	for i := 0; i < 20; i++ {
+		path := fmt.Sprintf("f%02d.py", i)
+		fileChanges = append(fileChanges, git.FileChanges{FilePath: path})
+		delays[path] = time.Duration(20-i) * time.Millisecond
+	}
+
+	var running, peak int32
+	var log bytes.Buffer
+	results := FormatFiles(fileChanges, 4, &log, func(fc git.FileChanges, w io.Writer) FileResult {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		// Later files finish first
+		fmt.Fprintf(w, "start %s\n", fc.FilePath)
+		time.Sleep(delays[fc.FilePath])
+		fmt.Fprintf(w, "end %s\n", fc.FilePath)
+
+		atomic.AddInt32(&running, -1)
+		return FileResult{FilePath: fc.FilePath}
+	})
+
+	if len(results) != len(fileChanges) {
+		t.Fatalf("Expected %d results, got %d", len(fileChanges), len(results))
+	}
+
+	var expectedLog bytes.Buffer
+	for i, fc := range fileChanges {
+		if results[i].FilePath != fc.FilePath {
+			t.Errorf("Result %d: expected %s, got %s", i, fc.FilePath, results[i].FilePath)
+		}
+		fmt.Fprintf(&expectedLog, "start %s\nend %s\n", fc.FilePath, fc.FilePath)
+	}
+	if log.String() != expectedLog.String() {
+		t.Errorf("Expected log in file order, got:\n%s", log.String())
+	}
+
+	if peak > 4 {
+		t.Errorf("Expected at most 4 files in parallel, got %d", peak)
+	}
+}
+
+func TestFormatFilesSingleJob(t *testing.T) {
+	fileChanges := []git.FileChanges{{FilePath: "a.py"}, {FilePath: "b.py"}}
+
+	var order []string
+	results := FormatFiles(fileChanges, 0, io.Discard, func(fc git.FileChanges, w io.Writer) FileResult {
+		order = append(order, fc.FilePath)
+		return FileResult{FilePath: fc.FilePath}
+	})
+
+	if len(results) != 2 || order[0] != "a.py" || order[1] != "b.py" {
+		t.Errorf("Expected files formatted one after another in order, got %v", order)
+	}
+}
+
+func TestFormatFilesEmpty(t *testing.T) {
+	results := FormatFiles(nil, 8, io.Discard, func(fc git.FileChanges, w io.Writer) FileResult {
+		t.Errorf("Unexpected call for %s", fc.FilePath)
+		return FileResult{}
+	})
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %v", results)
+	}
+}
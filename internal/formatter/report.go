@@ -0,0 +1,162 @@
+package formatter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"time"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// Status is the outcome of formatting a line range
+type Status string
+
+const (
+	// StatusFormatted means the range was reformatted
+	StatusFormatted Status = "formatted"
+	// StatusUnchanged means the range was already formatted
+	StatusUnchanged Status = "unchanged"
+	// StatusWouldReformat means the range needs formatting (dry-run mode)
+	StatusWouldReformat Status = "would-reformat"
+	// StatusFailed means the formatter failed on the range
+	StatusFailed Status = "failed"
+)
+
+// RangeResult is the outcome of formatting a single line range. Backends that
+// format all ranges of a file in one invocation attribute the changes to the
+// ranges they touch.
+type RangeResult struct {
+	Range    git.LineRange
+	Status   Status
+	Stderr   string
+	Error    string
+	Duration time.Duration
+}
+
+// FileResult is the outcome of formatting the changed ranges of a file
+type FileResult struct {
+	FilePath   string
+	BeforeHash string
+	AfterHash  string
+	Ranges     []RangeResult
+	// Diff holds the changes the formatter would make in dry-run mode
+	Diff     string
+	Duration time.Duration
+	// RolledBack is set when the file was restored to BeforeHash after a failure
+	RolledBack bool
+	// OutOfRange lists the original lines of edits outside the changed ranges,
+	// found by a strict ranges check. OutOfRangeReverted is set if they were undone.
+	OutOfRange         []git.LineRange
+	OutOfRangeReverted bool
+}
+
+// Report is the outcome of a formatting run
+type Report struct {
+	Formatter string
+	DryRun    bool
+	Files     []FileResult
+	Duration  time.Duration
+}
+
+// Status returns the most significant status among the ranges of the file
+func (f FileResult) Status() Status {
+	status := StatusUnchanged
+	for _, rr := range f.Ranges {
+		switch rr.Status {
+		case StatusFailed:
+			return StatusFailed
+		case StatusWouldReformat:
+			status = StatusWouldReformat
+		case StatusFormatted:
+			if status == StatusUnchanged {
+				status = StatusFormatted
+			}
+		}
+	}
+	return status
+}
+
+// CountRanges returns the number of ranges with the given status
+func (r *Report) CountRanges(status Status) int {
+	count := 0
+	for _, f := range r.Files {
+		for _, rr := range f.Ranges {
+			if rr.Status == status {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// CountFiles returns the number of files with the given status
+func (r *Report) CountFiles(status Status) int {
+	count := 0
+	for _, f := range r.Files {
+		if f.Status() == status {
+			count++
+		}
+	}
+	return count
+}
+
+// HasFailures reports whether the formatter failed on any range
+func (r *Report) HasFailures() bool {
+	return r.CountRanges(StatusFailed) > 0
+}
+
+// NeedsFormatting reports whether any range would be reformatted in dry-run mode
+func (r *Report) NeedsFormatting() bool {
+	return r.CountRanges(StatusWouldReformat) > 0
+}
+
+// MarkRolledBack records that the given files were restored to their content
+// before formatting
+func (r *Report) MarkRolledBack(paths []string) {
+	restored := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		restored[p] = true
+	}
+	for i := range r.Files {
+		if restored[r.Files[i].FilePath] {
+			r.Files[i].RolledBack = true
+			r.Files[i].AfterHash = r.Files[i].BeforeHash
+		}
+	}
+}
+
+// CountRolledBack returns the number of files restored after a failure
+func (r *Report) CountRolledBack() int {
+	count := 0
+	for _, f := range r.Files {
+		if f.RolledBack {
+			count++
+		}
+	}
+	return count
+}
+
+// CountOutOfRange returns the number of edits outside the changed ranges
+func (r *Report) CountOutOfRange() int {
+	count := 0
+	for _, f := range r.Files {
+		count += len(f.OutOfRange)
+	}
+	return count
+}
+
+// HashContent returns the hex encoded SHA-256 hash of content
+func HashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// HashFile returns the hash of a file's content, or an empty string if it can't be read
+func HashFile(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return HashContent(content)
+}
@@ -0,0 +1,111 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+func TestFileResultStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []Status
+		expected Status
+	}{
+		{"no ranges", nil, StatusUnchanged},
+		{"all unchanged", []Status{StatusUnchanged, StatusUnchanged}, StatusUnchanged},
+		{"one formatted", []Status{StatusUnchanged, StatusFormatted}, StatusFormatted},
+		{"would reformat wins over formatted", []Status{StatusFormatted, StatusWouldReformat}, StatusWouldReformat},
+		{"failure wins", []Status{StatusWouldReformat, StatusFailed, StatusFormatted}, StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f FileResult
+			for i, s := range tt.statuses {
+				f.Ranges = append(f.Ranges, RangeResult{Range: git.LineRange{Start: i + 1, End: i + 1}, Status: s})
+			}
+			if got := f.Status(); got != tt.expected {
+				t.Errorf("Status() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReportCounts(t *testing.T) {
+	report := &Report{
+		Formatter: "ruff",
+		Files: []FileResult{
+			{FilePath: "a.py", Ranges: []RangeResult{{Status: StatusWouldReformat}, {Status: StatusUnchanged}}},
+			{FilePath: "b.py", Ranges: []RangeResult{{Status: StatusWouldReformat}}},
+			{FilePath: "c.py", Ranges: []RangeResult{{Status: StatusFailed, Error: "boom"}}},
+		},
+	}
+
+	if n := report.CountRanges(StatusWouldReformat); n != 2 {
+		t.Errorf("Expected 2 ranges to be reformatted, got %d", n)
+	}
+	if n := report.CountFiles(StatusWouldReformat); n != 2 {
+		t.Errorf("Expected 2 files to be reformatted, got %d", n)
+	}
+	if !report.NeedsFormatting() {
+		t.Errorf("Expected NeedsFormatting to be true")
+	}
+	if !report.HasFailures() {
+		t.Errorf("Expected HasFailures to be true")
+	}
+
+	err := FailureError(report)
+	if err == nil {
+		t.Fatalf("Expected failure error, got nil")
+	}
+	if err.Error() != "ruff failed on 1 file(s): c.py: boom" {
+		t.Errorf("Unexpected failure error: %v", err)
+	}
+}
+
+func TestHashContent(t *testing.T) {
+	if HashContent([]byte("a")) == HashContent([]byte("b")) {
+		t.Errorf("Expected different hashes for different content")
+	}
+	if HashFile("/does/not/exist") != "" {
+		t.Errorf("Expected empty hash for a missing file")
+	}
+}
+
+func TestFailureErrorListsEveryFailedFile(t *testing.T) {
+	report := &Report{
+		Formatter: "ruff",
+		Files: []FileResult{
+			{FilePath: "a.py", Ranges: []RangeResult{{Status: StatusFailed, Error: "boom"}, {Status: StatusFailed, Error: "again"}}},
+			{FilePath: "b.py", Ranges: []RangeResult{{Status: StatusFormatted}}},
+			{FilePath: "c.py", Ranges: []RangeResult{{Status: StatusFailed, Error: "bang"}}},
+		},
+	}
+
+	expected := "ruff failed on 2 file(s):\n  a.py: boom\n  c.py: bang"
+	if err := FailureError(report); err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
+
+func TestMarkRolledBack(t *testing.T) {
+	report := &Report{
+		Files: []FileResult{
+			{FilePath: "a.py", BeforeHash: "old", AfterHash: "new"},
+			{FilePath: "b.py", BeforeHash: "same", AfterHash: "same"},
+		},
+	}
+
+	report.MarkRolledBack([]string{"a.py"})
+
+	if !report.Files[0].RolledBack || report.Files[0].AfterHash != "old" {
+		t.Errorf("Expected a.py to be rolled back to its original hash, got %+v", report.Files[0])
+	}
+	if report.Files[1].RolledBack {
+		t.Errorf("Expected b.py not to be rolled back")
+	}
+	if n := report.CountRolledBack(); n != 1 {
+		t.Errorf("Expected 1 rolled back file, got %d", n)
+	}
+}
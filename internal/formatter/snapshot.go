@@ -0,0 +1,69 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// Snapshot holds the content of files before formatting, so they can be
+// restored if the formatter fails partway through
+type Snapshot struct {
+	repoRoot string
+	paths    []string
+	contents map[string][]byte
+}
+
+// TakeSnapshot reads the current content of every file in fileChanges
+func TakeSnapshot(repoRoot string, fileChanges []git.FileChanges) (*Snapshot, error) {
+	s := &Snapshot{repoRoot: repoRoot, contents: make(map[string][]byte, len(fileChanges))}
+
+	for _, fc := range fileChanges {
+		if _, ok := s.contents[fc.FilePath]; ok {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(repoRoot, fc.FilePath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to snapshot %s: %w", fc.FilePath, err)
+		}
+		s.paths = append(s.paths, fc.FilePath)
+		s.contents[fc.FilePath] = content
+	}
+
+	return s, nil
+}
+
+// Content returns the snapshotted content of a file
+func (s *Snapshot) Content(path string) ([]byte, bool) {
+	content, ok := s.contents[path]
+	return content, ok
+}
+
+// Restore writes the snapshotted content back to every file that changed since
+// the snapshot was taken and returns the paths of the restored files. It keeps
+// going when a file cannot be restored and reports the first such error.
+func (s *Snapshot) Restore() ([]string, error) {
+	var restored []string
+	var firstErr error
+
+	for _, path := range s.paths {
+		absPath := filepath.Join(s.repoRoot, path)
+		current, err := os.ReadFile(absPath)
+		if err == nil && bytes.Equal(current, s.contents[path]) {
+			continue
+		}
+
+		if err := WriteFileAtomic(absPath, s.contents[path]); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to restore %s: %w", path, err)
+			}
+			continue
+		}
+		restored = append(restored, path)
+	}
+
+	return restored, firstErr
+}
@@ -0,0 +1,46 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	repoRoot := t.TempDir()
+	for name, content := range map[string]string{"a.py": "a=1\n", "b.py": "b=2\n"} {
+		if err := os.WriteFile(filepath.Join(repoRoot, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	snapshot, err := TakeSnapshot(repoRoot, []git.FileChanges{{FilePath: "a.py"}, {FilePath: "b.py"}, {FilePath: "a.py"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Only a.py is modified after the snapshot
+	if err := os.WriteFile(filepath.Join(repoRoot, "a.py"), []byte("a = 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	restored, err := snapshot.Restore()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(restored, []string{"a.py"}) {
+		t.Errorf("Expected only a.py to be restored, got %v", restored)
+	}
+	if content, _ := os.ReadFile(filepath.Join(repoRoot, "a.py")); string(content) != "a=1\n" {
+		t.Errorf("Expected original content, got %q", content)
+	}
+}
+
+func TestTakeSnapshotMissingFile(t *testing.T) {
+	if _, err := TakeSnapshot(t.TempDir(), []git.FileChanges{{FilePath: "missing.py"}}); err == nil {
+		t.Errorf("Expected error for a missing file, got nil")
+	}
+}
@@ -0,0 +1,100 @@
+package formatter
+
+import (
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/diff"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// EnforceRanges compares formatted content with the original and finds the edits
+// that touch lines outside the permitted ranges, which refer to lines of the
+// original. It returns the formatted content with those edits reverted and the
+// original lines they covered. An edit that only inserts lines is permitted when
+// it is inside or directly next to a range, e.g. blank lines added around a
+// reformatted statement.
+func EnforceRanges(original, formatted []byte, ranges []git.LineRange) ([]byte, []git.LineRange) {
+	oldLines := diff.SplitLines(string(original))
+	newLines := diff.SplitLines(string(formatted))
+
+	var result strings.Builder
+	var outside []git.LineRange
+
+	next := 0
+	for _, e := range splitEdits(diff.Lines(oldLines, newLines)) {
+		for _, line := range oldLines[next:e.OldStart] {
+			result.WriteString(line)
+		}
+		next = e.OldEnd
+
+		if editInRanges(e, ranges) {
+			for _, line := range newLines[e.NewStart:e.NewEnd] {
+				result.WriteString(line)
+			}
+			continue
+		}
+
+		for _, line := range oldLines[e.OldStart:e.OldEnd] {
+			result.WriteString(line)
+		}
+		outside = append(outside, editSpan(e, len(oldLines)))
+	}
+	for _, line := range oldLines[next:] {
+		result.WriteString(line)
+	}
+
+	if len(outside) == 0 {
+		return formatted, nil
+	}
+	return []byte(result.String()), outside
+}
+
+// splitEdits breaks edits that replace lines one for one into single line edits,
+// so a run of reformatted lines that crosses a range boundary can be split there.
+// Edits that change the number of lines can't be mapped line by line and are kept whole.
+func splitEdits(edits []diff.Edit) []diff.Edit {
+	var split []diff.Edit
+	for _, e := range edits {
+		if e.OldEnd-e.OldStart != e.NewEnd-e.NewStart {
+			split = append(split, e)
+			continue
+		}
+		for i := 0; i < e.OldEnd-e.OldStart; i++ {
+			split = append(split, diff.Edit{
+				OldStart: e.OldStart + i,
+				OldEnd:   e.OldStart + i + 1,
+				NewStart: e.NewStart + i,
+				NewEnd:   e.NewStart + i + 1,
+			})
+		}
+	}
+	return split
+}
+
+// editInRanges reports whether all original lines replaced by an edit lie within
+// a single range. Pure insertions must be inside or directly next to a range.
+func editInRanges(e diff.Edit, ranges []git.LineRange) bool {
+	for _, lr := range ranges {
+		if e.OldStart == e.OldEnd {
+			// The lines are inserted after original line OldStart
+			if e.OldStart >= lr.Start-1 && e.OldStart <= lr.End {
+				return true
+			}
+			continue
+		}
+		if e.OldStart+1 >= lr.Start && e.OldEnd <= lr.End {
+			return true
+		}
+	}
+	return false
+}
+
+// editSpan returns the one-based original lines an edit covers. A pure insertion
+// is attributed to the line it was inserted before, or the last line at the end.
+func editSpan(e diff.Edit, lineCount int) git.LineRange {
+	if e.OldStart == e.OldEnd {
+		line := min(e.OldStart+1, max(lineCount, 1))
+		return git.LineRange{Start: line, End: line}
+	}
+	return git.LineRange{Start: e.OldStart + 1, End: e.OldEnd}
+}
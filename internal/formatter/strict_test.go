@@ -0,0 +1,70 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+func TestEnforceRanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		original  string
+		formatted string
+		ranges    []git.LineRange
+		expected  string
+		outside   []git.LineRange
+	}{
+		{
+			name:      "edits inside ranges are kept",
+			original:  "a=1\nb=2\nc=3\n",
+			formatted: "a=1\nb = 2\nc=3\n",
+			ranges:    []git.LineRange{{Start: 2, End: 2}},
+			expected:  "a=1\nb = 2\nc=3\n",
+		},
+		{
+			name:      "edits outside ranges are reverted",
+			original:  "a=1\nb=2\nc=3\nd=4\n",
+			formatted: "a = 1\nb = 2\nc=3\nd = 4\n",
+			ranges:    []git.LineRange{{Start: 2, End: 2}},
+			expected:  "a=1\nb = 2\nc=3\nd=4\n",
+			outside:   []git.LineRange{{Start: 1, End: 1}, {Start: 4, End: 4}},
+		},
+		{
+			name:      "edits crossing a range boundary are reverted",
+			original:  "f(a,\n  b)\nc=3\n",
+			formatted: "f(a, b)\nc=3\n",
+			ranges:    []git.LineRange{{Start: 1, End: 1}},
+			expected:  "f(a,\n  b)\nc=3\n",
+			outside:   []git.LineRange{{Start: 1, End: 2}},
+		},
+		{
+			name:      "insertions next to a range are kept",
+			original:  "import os\ndef f():\n    pass\n",
+			formatted: "import os\n\n\ndef f():\n    pass\n",
+			ranges:    []git.LineRange{{Start: 2, End: 3}},
+			expected:  "import os\n\n\ndef f():\n    pass\n",
+		},
+		{
+			name:      "insertions away from ranges are reverted",
+			original:  "x=1\ny=2\nz=3\n",
+			formatted: "x=1\ny=2\n\nz=3\n",
+			ranges:    []git.LineRange{{Start: 1, End: 1}},
+			expected:  "x=1\ny=2\nz=3\n",
+			outside:   []git.LineRange{{Start: 3, End: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, outside := EnforceRanges([]byte(tt.original), []byte(tt.formatted), tt.ranges)
+			if string(result) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+			if !reflect.DeepEqual(outside, tt.outside) {
+				t.Errorf("Expected edits outside %v, got %v", tt.outside, outside)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package formatter
+
+import (
+	"github.com/horiagug/ruff-format-changes/internal/diff"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// RangeStatuses attributes the edits a formatter made to a file to its ranges,
+// which refer to lines of the original. A range gets the changed status when an
+// edit replaces any of its lines or inserts lines inside or directly next to it,
+// and StatusUnchanged otherwise. It is meant for files the formatter changed, so
+// when no edit touches a range, including when there are no edits because they
+// could not be recovered, every range gets the changed status.
+func RangeStatuses(edits []diff.Edit, ranges []git.LineRange, changed Status) []Status {
+	statuses := make([]Status, len(ranges))
+	touched := false
+	for i, lr := range ranges {
+		statuses[i] = StatusUnchanged
+		for _, e := range edits {
+			if editTouches(e, lr) {
+				statuses[i] = changed
+				touched = true
+				break
+			}
+		}
+	}
+
+	if !touched {
+		for i := range statuses {
+			statuses[i] = changed
+		}
+	}
+	return statuses
+}
+
+// ContentRangeStatuses is RangeStatuses for the edits between the original and
+// formatted content of a file
+func ContentRangeStatuses(original, formatted []byte, ranges []git.LineRange, changed Status) []Status {
+	edits := diff.Lines(diff.SplitLines(string(original)), diff.SplitLines(string(formatted)))
+	return RangeStatuses(edits, ranges, changed)
+}
+
+// editTouches reports whether an edit replaces a line of the range or inserts
+// lines inside or directly next to it
+func editTouches(e diff.Edit, lr git.LineRange) bool {
+	if e.OldStart == e.OldEnd {
+		// The lines are inserted after original line OldStart
+		return e.OldStart >= lr.Start-1 && e.OldStart <= lr.End
+	}
+	return e.OldStart < lr.End && e.OldEnd >= lr.Start
+}
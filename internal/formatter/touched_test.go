@@ -0,0 +1,47 @@
+package formatter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+func TestContentRangeStatuses(t *testing.T) {
+	ranges := []git.LineRange{{Start: 1, End: 1}, {Start: 3, End: 4}}
+	tests := []struct {
+		name      string
+		formatted string
+		expected  []Status
+	}{
+		{
+			name:      "edit in one range",
+			formatted: "a=1\nb=2\nc = 3\nd=4\ne=5\n",
+			expected:  []Status{StatusUnchanged, StatusFormatted},
+		},
+		{
+			name:      "edit spanning both ranges",
+			formatted: "a = 1\nb = 2\nc = 3\nd=4\ne=5\n",
+			expected:  []Status{StatusFormatted, StatusFormatted},
+		},
+		{
+			name:      "insertion next to a range",
+			formatted: "a=1\nb=2\nc=3\nd=4\n\ne=5\n",
+			expected:  []Status{StatusUnchanged, StatusFormatted},
+		},
+		{
+			name:      "edit outside every range",
+			formatted: "a=1\nb=2\nc=3\nd=4\ne = 5\n",
+			expected:  []Status{StatusFormatted, StatusFormatted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ContentRangeStatuses([]byte("a=1\nb=2\nc=3\nd=4\ne=5\n"), []byte(tt.formatted), ranges, StatusFormatted)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package formatter
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// NewYapf creates a formatter backed by yapf, which formats ranges with --lines
+func NewYapf(repoRoot string, dryRun, verbose bool) Formatter {
+	return &commandFormatter{
+		name:     "yapf",
+		install:  "pip install yapf",
+		repoRoot: repoRoot,
+		dryRun:   dryRun,
+		verbose:  verbose,
+		jobs:     runtime.NumCPU(),
+		log:      os.Stdout,
+		rangeArgs: func(ranges []git.LineRange) []string {
+			return lineRangeArgs("--lines", ranges)
+		},
+		writeArgs: []string{"--in-place"},
+		checkArgs: []string{"--diff"},
+		// yapf reads stdin when no files are given and has no option to name it
+		stdinArgs: func(filePath string) []string {
+			return nil
+		},
+		changedExitCode: 1,
+	}
+}
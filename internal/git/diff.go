@@ -0,0 +1,249 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// diffLineRanges runs a single git diff with the given arguments and returns the
+// changed line ranges of every Python file in it. The diff is taken without
+// context lines, since only added lines are reported.
+func (g *Git) diffLineRanges(args ...string) ([]FileChanges, error) {
+	return g.diffLineRangesWithEnv(nil, args...)
+}
+
+// diffLineRangesWithEnv is diffLineRanges with extra environment variables for git
+func (g *Git) diffLineRangesWithEnv(env []string, args ...string) ([]FileChanges, error) {
+	// -z only affects the raw and name formats, not patches, so quoted paths
+	// in the patch headers are unquoted by the parser instead
+	diffArgs := append([]string{"diff", "--no-color", "--no-ext-diff", "-U0", "--src-prefix=a/", "--dst-prefix=b/"}, g.diffOptions.args()...)
+	cmd := g.command(append(diffArgs, args...)...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get diff: %w", err)
+	}
+
+	allChanges, err := parseMultiFileDiff(string(output))
+	if err != nil {
+		return nil, err
+	}
+
+	var fileChangesList []FileChanges
+	for _, fc := range allChanges {
+		if !g.selects(fc.FilePath) {
+			continue
+		}
+		if g.verbose && fc.OldPath != "" {
+			fmt.Fprintf(g.log, "Detected %s as renamed or copied from %s\n", fc.FilePath, fc.OldPath)
+		}
+		fileChangesList = append(fileChangesList, fc)
+	}
+	return fileChangesList, nil
+}
+
+// diffWorkingTreeLineRanges returns the changed line ranges between base and the
+// working tree, including untracked files. With rename detection, untracked
+// files are added as intent-to-add entries to a temporary copy of the index, so
+// a file moved without git mv is matched with its old path and only the lines
+// that differ from it are reported. The real index is never modified.
+func (g *Git) diffWorkingTreeLineRanges(base string) ([]FileChanges, error) {
+	untracked := g.getUntrackedFiles()
+	if len(untracked) == 0 || !g.diffOptions.Renames {
+		fileChangesList, err := g.diffLineRanges(base)
+		if err != nil {
+			return nil, err
+		}
+		return append(fileChangesList, g.getUntrackedLineRanges(untracked)...), nil
+	}
+
+	indexFile, err := g.intentToAddIndex(untracked)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(indexFile)
+
+	return g.diffLineRangesWithEnv([]string{"GIT_INDEX_FILE=" + indexFile}, base)
+}
+
+// intentToAddIndex copies the index to a temporary file and adds files to it as
+// intent-to-add, so diffs against a commit include them as new files. The
+// caller removes the returned file.
+func (g *Git) intentToAddIndex(files []string) (string, error) {
+	output, err := g.command("rev-parse", "--git-path", "index").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find index: %w", err)
+	}
+	indexPath := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(indexPath) {
+		indexPath = filepath.Join(g.repoRoot, indexPath)
+	}
+
+	tmp, err := os.CreateTemp("", "ruff-format-changes-index-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	// A repository without commits or staged files has no index yet
+	index, err := os.ReadFile(indexPath)
+	if err == nil {
+		_, err = tmp.Write(index)
+	} else if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to copy index: %w", err)
+	}
+
+	cmd := g.command(append([]string{"add", "--intent-to-add", "--"}, files...)...)
+	cmd.Env = append(os.Environ(), "GIT_INDEX_FILE="+tmpPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to add untracked files to temporary index: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return tmpPath, nil
+}
+
+// getUntrackedFiles returns the untracked Python files that are not ignored
+func (g *Git) getUntrackedFiles() []string {
+	output, err := g.command("ls-files", "--others", "--exclude-standard").Output()
+	if err != nil {
+		if g.verbose {
+			fmt.Fprintf(g.log, "Warning: could not get untracked files: %v\n", err)
+		}
+		return nil
+	}
+
+	var files []string
+	for _, file := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if file != "" && g.selects(file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// getUntrackedLineRanges returns a range covering the whole file for every
+// untracked file
+func (g *Git) getUntrackedLineRanges(files []string) []FileChanges {
+	var fileChangesList []FileChanges
+	for _, file := range files {
+		lineCount, err := getFileLineCount(filepath.Join(g.repoRoot, file))
+		if err != nil {
+			if g.verbose {
+				fmt.Fprintf(g.log, "Warning: Could not count lines in %s: %v\n", file, err)
+			}
+			continue
+		}
+		if lineCount == 0 {
+			continue
+		}
+
+		fileChangesList = append(fileChangesList, FileChanges{
+			FilePath:   file,
+			LineRanges: []LineRange{{Start: 1, End: lineCount}},
+		})
+	}
+	return fileChangesList
+}
+
+// parseMultiFileDiff splits the output of git diff into per-file sections and
+// parses the hunks of each with parseHunks. Files are reported under their
+// new path, with the path a renamed or copied file came from as OldPath, so they
+// keep only the lines that differ from it. Files that only lose lines are kept
+// with their seams. Deleted files, binary files and pure mode, rename or copy
+// changes are skipped.
+func parseMultiFileDiff(diff string) ([]FileChanges, error) {
+	var fileChangesList []FileChanges
+
+	var newPath, oldPath string
+	var hunks []string
+	inHeader := false
+
+	finalizeFile := func() error {
+		if newPath == "" || len(hunks) == 0 {
+			return nil
+		}
+		ranges, seams, err := parseHunks(strings.Join(hunks, "\n"))
+		if err != nil {
+			return fmt.Errorf("failed to parse diff for %s: %w", newPath, err)
+		}
+		if len(ranges) > 0 || len(seams) > 0 {
+			fileChangesList = append(fileChangesList, FileChanges{FilePath: newPath, LineRanges: ranges, OldPath: oldPath, Seams: seams})
+		}
+		return nil
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			if err := finalizeFile(); err != nil {
+				return nil, err
+			}
+			newPath, oldPath, hunks, inHeader = "", "", nil, true
+			continue
+		}
+
+		// Hunk lines may themselves start with "+++ ", so headers are only
+		// read before the first hunk of a file
+		if inHeader {
+			if strings.HasPrefix(line, "@@") {
+				inHeader = false
+			} else {
+				switch {
+				case strings.HasPrefix(line, "+++ "):
+					path, err := diffPath(strings.TrimPrefix(line, "+++ "), "b/")
+					if err != nil {
+						return nil, err
+					}
+					newPath = path
+				case strings.HasPrefix(line, "rename from "), strings.HasPrefix(line, "copy from "):
+					path, err := diffPath(line[strings.Index(line, " from ")+len(" from "):], "")
+					if err != nil {
+						return nil, err
+					}
+					oldPath = path
+				}
+				continue
+			}
+		}
+
+		hunks = append(hunks, line)
+	}
+
+	if err := finalizeFile(); err != nil {
+		return nil, err
+	}
+	return fileChangesList, nil
+}
+
+// diffPath decodes a path from a ---/+++ header line, removing git's C-style
+// quoting, the trailing tab added to paths with spaces and the given prefix.
+// It returns "" for /dev/null.
+func diffPath(value, prefix string) (string, error) {
+	value = strings.TrimSuffix(value, "\t")
+	if value == "/dev/null" {
+		return "", nil
+	}
+
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid path %s in diff: %w", value, err)
+		}
+		value = unquoted
+	}
+
+	return strings.TrimPrefix(value, prefix), nil
+}
@@ -0,0 +1,329 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Tests for parseMultiFileDiff function
+
+func TestParseMultiFileDiffSeveralFiles(t *testing.T) {
+	diff := `diff --git a/a.py b/a.py
+index 1111111..2222222 100644
+--- a/a.py
++++ b/a.py
+@@ -2 +2,2 @@
+-b
++B
++c
+diff --git a/new.py b/new.py
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.py
+@@ -0,0 +1,3 @@
++x
++y
++z
+diff --git a/gone.py b/gone.py
+deleted file mode 100644
+index 4444444..0000000
+--- a/gone.py
++++ /dev/null
+@@ -1,2 +0,0 @@
+-old
+-lines
+`
+	changes, err := parseMultiFileDiff(diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []FileChanges{
+		{FilePath: "a.py", LineRanges: []LineRange{{Start: 2, End: 3}}},
+		{FilePath: "new.py", LineRanges: []LineRange{{Start: 1, End: 3}}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+}
+
+func TestParseMultiFileDiffRenamesAndModes(t *testing.T) {
+	diff := `diff --git a/old.py b/moved.py
+similarity index 80%
+rename from old.py
+rename to moved.py
+index 1111111..2222222 100644
+--- a/old.py
++++ b/moved.py
+@@ -5 +5 @@
+-x=1
++x = 1
+diff --git a/run.py b/run.py
+old mode 100644
+new mode 100755
+diff --git a/same.py b/renamed.py
+similarity index 100%
+rename from same.py
+rename to renamed.py
+diff --git a/logo.png b/logo.png
+index 5555555..6666666 100644
+Binary files a/logo.png and b/logo.png differ
+diff --git a/base.py "b/copy of base.py"
+similarity index 90%
+copy from base.py
+copy to "copy of base.py"
+index 3333333..4444444 100644
+--- a/base.py
++++ "b/copy of base.py"
+@@ -2,0 +3 @@
++y = 2
+`
+	changes, err := parseMultiFileDiff(diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Mode-only changes, pure renames and binary files have no changed lines
+	expected := []FileChanges{
+		{FilePath: "moved.py", LineRanges: []LineRange{{Start: 5, End: 5}}, OldPath: "old.py"},
+		{FilePath: "copy of base.py", LineRanges: []LineRange{{Start: 3, End: 3}}, OldPath: "base.py"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+}
+
+func TestParseMultiFileDiffHunkLinesLookLikeHeaders(t *testing.T) {
+	// Added lines "++ b/x" and "diff" appear as "+++ b/x" and "+diff" in the hunk
+	diff := `diff --git a/a.py b/a.py
+index 1111111..2222222 100644
+--- a/a.py
++++ b/a.py
+@@ -0,0 +1,2 @@
++++ b/x
++diff --git a/y b/y
+`
+	changes, err := parseMultiFileDiff(diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []FileChanges{
+		{FilePath: "a.py", LineRanges: []LineRange{{Start: 1, End: 2}}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+}
+
+func TestDiffPath(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"b/main.py", "main.py"},
+		{"b/with space.py\t", "with space.py"},
+		{`"b/n\303\251.py"`, "né.py"},
+		{`"b/tab\there.py"`, "tab\there.py"},
+		{"/dev/null", ""},
+	}
+
+	for _, tt := range tests {
+		path, err := diffPath(tt.value, "b/")
+		if err != nil {
+			t.Errorf("diffPath(%q): unexpected error %v", tt.value, err)
+			continue
+		}
+		if path != tt.expected {
+			t.Errorf("diffPath(%q): expected %q, got %q", tt.value, tt.expected, path)
+		}
+	}
+}
+
+func TestGetChangedLineRangesSingleDiff(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "other.py"), []byte("q = 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	runGit(t, tmpDir, "add", "other.py")
+	runGit(t, tmpDir, "commit", "-m", "Add other.py")
+
+	// Rename with an edit, a path with special characters and an untracked file
+	runGit(t, tmpDir, "mv", "main.py", "renamed.py")
+	if err := os.WriteFile(filepath.Join(tmpDir, "renamed.py"), []byte("a = 1\n\n\n\n\n\n\n\n\n\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	runGit(t, tmpDir, "mv", "other.py", "spaced é.py")
+	if err := os.WriteFile(filepath.Join(tmpDir, "spaced é.py"), []byte("q = 1\nr = 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "untracked.py"), []byte("u = 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	changes, err := g.GetChangedLineRanges("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to get changed line ranges: %v", err)
+	}
+
+	got := make(map[string][]LineRange)
+	for _, fc := range changes {
+		got[fc.FilePath] = fc.LineRanges
+	}
+	expected := map[string][]LineRange{
+		"renamed.py":   {{Start: 11, End: 11}},
+		"spaced é.py":  {{Start: 2, End: 2}},
+		"untracked.py": {{Start: 1, End: 1}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestGetChangedLineRangesMovedUntrackedFile(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+
+	// Move main.py without git mv and edit its last line
+	if err := os.Remove(filepath.Join(tmpDir, "main.py")); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "moved.py"), []byte("a = 1\n\n\n\n\n\n\n\n\n\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	statusBefore := runGit(t, tmpDir, "status", "--porcelain")
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	changes, err := g.GetChangedLineRanges("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to get changed line ranges: %v", err)
+	}
+	expected := []FileChanges{{FilePath: "moved.py", LineRanges: []LineRange{{Start: 11, End: 11}}, OldPath: "main.py"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+
+	// The index is left as it was
+	if status := runGit(t, tmpDir, "status", "--porcelain"); status != statusBefore {
+		t.Errorf("Expected git status %q, got %q", statusBefore, status)
+	}
+
+	// Without rename detection the moved file is new
+	if err := g.SetDiffOptions(DiffOptions{Similarity: 50}); err != nil {
+		t.Fatalf("SetDiffOptions failed: %v", err)
+	}
+	changes, err = g.GetChangedLineRanges("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to get changed line ranges: %v", err)
+	}
+	expected = []FileChanges{{FilePath: "moved.py", LineRanges: []LineRange{{Start: 1, End: 11}}}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v without renames, got %v", expected, changes)
+	}
+}
+
+func TestGetChangedLineRangesCopies(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+
+	// Copy detection considers files modified in the same diff as sources
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte("a = 1\n\n\n\n\n\n\n\n\n\nb = 3\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "copy.py"), []byte("a = 1\n\n\n\n\n\n\n\n\n\nb = 2\nc=3\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	runGit(t, tmpDir, "add", "main.py", "copy.py")
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+	if err := g.SetDiffOptions(DiffOptions{Renames: true, Copies: true, Similarity: 50}); err != nil {
+		t.Fatalf("SetDiffOptions failed: %v", err)
+	}
+
+	changes, err := g.GetStagedLineRanges()
+	if err != nil {
+		t.Fatalf("Failed to get staged line ranges: %v", err)
+	}
+	expected := []FileChanges{
+		{FilePath: "copy.py", LineRanges: []LineRange{{Start: 12, End: 12}}, OldPath: "main.py"},
+		{FilePath: "main.py", LineRanges: []LineRange{{Start: 11, End: 11}}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+}
+
+func TestSetDiffOptionsInvalidSimilarity(t *testing.T) {
+	g := &Git{diffOptions: DefaultDiffOptions()}
+	for _, similarity := range []int{-1, 0, 101} {
+		if err := g.SetDiffOptions(DiffOptions{Renames: true, Similarity: similarity}); err == nil {
+			t.Errorf("Expected error for similarity %d, got nil", similarity)
+		}
+	}
+}
+
+func TestGetChangedLineRangesIgnoredChanges(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+
+	content := "def f():\n    x = 1\n    return x\n\n\ny = 2\nz = 3\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "f.py"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	runGit(t, tmpDir, "add", "f.py")
+	runGit(t, tmpDir, "commit", "-m", "Add f.py")
+
+	// Re-indent a line, add a comment and a blank line, and change z
+	edited := "def f():\n      x = 1\n    # comment\n    return x\n\n\n\ny = 2\nz = 4\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "f.py"), []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     DiffOptions
+		expected []LineRange
+	}{
+		{name: "default", opts: DefaultDiffOptions(), expected: []LineRange{{Start: 2, End: 3}, {Start: 7, End: 7}, {Start: 9, End: 9}}},
+		{name: "whitespace", opts: DiffOptions{Renames: true, Similarity: 50, IgnoreWhitespace: true}, expected: []LineRange{{Start: 3, End: 3}, {Start: 7, End: 7}, {Start: 9, End: 9}}},
+		{name: "blank lines", opts: DiffOptions{Renames: true, Similarity: 50, IgnoreBlankLines: true}, expected: []LineRange{{Start: 2, End: 3}, {Start: 9, End: 9}}},
+		{
+			name:     "all",
+			opts:     DiffOptions{Renames: true, Similarity: 50, IgnoreWhitespace: true, IgnoreBlankLines: true, IgnoreComments: true},
+			expected: []LineRange{{Start: 9, End: 9}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := g.SetDiffOptions(tt.opts); err != nil {
+				t.Fatalf("SetDiffOptions failed: %v", err)
+			}
+			changes, err := g.GetChangedLineRanges("HEAD")
+			if err != nil {
+				t.Fatalf("Failed to get changed line ranges: %v", err)
+			}
+			if len(changes) != 1 || !reflect.DeepEqual(changes[0].LineRanges, tt.expected) {
+				t.Errorf("Expected ranges %v, got %+v", tt.expected, changes)
+			}
+		})
+	}
+}
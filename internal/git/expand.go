@@ -0,0 +1,172 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/pyscan"
+)
+
+// Expansion is the Python construct changed line ranges are widened to, since
+// formatting part of a statement can leave it half reformatted
+type Expansion string
+
+// Expansions of changed line ranges
+const (
+	// ExpandNone keeps the changed lines as they are
+	ExpandNone Expansion = ""
+	// ExpandStatement widens ranges to whole logical statements, including the
+	// decorators of a definition
+	ExpandStatement Expansion = "statement"
+	// ExpandFunction widens ranges to the enclosing function, or statement
+	// outside of functions
+	ExpandFunction Expansion = "function"
+	// ExpandClass widens ranges to the enclosing class, or statement outside of
+	// classes
+	ExpandClass Expansion = "class"
+)
+
+// Expansions are the available expansions besides ExpandNone
+var Expansions = []Expansion{ExpandStatement, ExpandFunction, ExpandClass}
+
+// ParseExpansion returns the expansion with the given name. An empty name is ExpandNone.
+func ParseExpansion(name string) (Expansion, error) {
+	if name == "" {
+		return ExpandNone, nil
+	}
+	for _, expansion := range Expansions {
+		if string(expansion) == name {
+			return expansion, nil
+		}
+	}
+	names := make([]string, len(Expansions))
+	for i, expansion := range Expansions {
+		names[i] = string(expansion)
+	}
+	return "", fmt.Errorf("unknown expansion %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// SetExpansion sets what the changed line ranges of Python files are widened to.
+// The default is ExpandNone.
+func (g *Git) SetExpansion(expansion Expansion) {
+	g.expansion = expansion
+}
+
+// ExpandLineRanges widens each range to the given construct of the Python source
+// src and merges ranges that end up overlapping or touching
+func ExpandLineRanges(src []byte, ranges []LineRange, expansion Expansion) []LineRange {
+	if expansion == ExpandNone || len(ranges) == 0 {
+		return ranges
+	}
+	return expandLineRanges(pyscan.Scan(src), ranges, expansion)
+}
+
+// expandLineRanges is ExpandLineRanges on scanned source
+func expandLineRanges(file *pyscan.File, ranges []LineRange, expansion Expansion) []LineRange {
+	expanded := make([]LineRange, 0, len(ranges))
+	for _, r := range ranges {
+		for _, line := range []int{r.Start, r.End} {
+			if start, end, ok := expandLine(file, line, expansion); ok {
+				r.Start = min(r.Start, start)
+				r.End = max(r.End, end)
+			}
+		}
+		expanded = append(expanded, r)
+	}
+	return NormalizeRanges(expanded, 0)
+}
+
+// expandLine returns the lines of the construct around a line, falling back to
+// its statement
+func expandLine(file *pyscan.File, line int, expansion Expansion) (int, int, bool) {
+	var keyword string
+	switch expansion {
+	case ExpandFunction:
+		keyword = "def"
+	case ExpandClass:
+		keyword = "class"
+	}
+	if keyword != "" {
+		if start, end, ok := file.Block(line, keyword); ok {
+			return start, end, true
+		}
+	}
+	return file.Span(line)
+}
+
+// widenRanges drops the changed ranges that only hold comments when
+// IgnoreComments is set, adds line ranges for the seams of each file according
+// to the seam policy and widens all ranges to the expansion, reading the new
+// content of a file with read. Ranges are then sorted and merged when they
+// overlap, touch or lie within the merge gap of each other, and files left
+// without line ranges are dropped. Notebooks are not Python source, so they only
+// get the lines around their seams and are never filtered, expanded or merged
+// across a gap.
+func (g *Git) widenRanges(fileChangesList []FileChanges, read func(path string) ([]byte, error)) []FileChanges {
+	var result []FileChanges
+	for _, fc := range fileChangesList {
+		notebook := strings.HasSuffix(fc.FilePath, ".ipynb")
+		seams := len(fc.Seams) > 0 && g.seamPolicy != SeamOff
+		expand := g.expansion != ExpandNone && !notebook
+		dropComments := g.diffOptions.IgnoreComments && !notebook
+
+		if seams || (expand || dropComments) && len(fc.LineRanges) > 0 {
+			content, err := read(fc.FilePath)
+			if err != nil {
+				if g.verbose {
+					fmt.Fprintf(g.log, "Warning: could not read %s to widen its changed lines: %v\n", fc.FilePath, err)
+				}
+			} else {
+				file := pyscan.Scan(content)
+				if dropComments {
+					fc.LineRanges = g.dropCommentRanges(file, fc)
+				}
+				if seams {
+					policy := g.seamPolicy
+					if notebook {
+						policy = SeamLine
+					}
+					seamRanges := seamLineRanges(file, fc.Seams, policy)
+					if g.verbose && len(seamRanges) > 0 {
+						fmt.Fprintf(g.log, "Formatting %d seam(s) of deleted lines in %s\n", len(seamRanges), fc.FilePath)
+					}
+					fc.LineRanges = NormalizeRanges(append(fc.LineRanges, seamRanges...), 0)
+				}
+				if expand {
+					fc.LineRanges = expandLineRanges(file, fc.LineRanges, g.expansion)
+				}
+			}
+		}
+
+		// Lines between the ranges of a notebook may belong to other cells
+		gap := g.mergeGap
+		if notebook {
+			gap = 0
+		}
+		merged := NormalizeRanges(fc.LineRanges, gap)
+		if g.verbose && len(merged) < len(fc.LineRanges) {
+			fmt.Fprintf(g.log, "Merged %d ranges of %s into %d (merge gap %d)\n", len(fc.LineRanges), fc.FilePath, len(merged), gap)
+		}
+		fc.LineRanges = merged
+
+		if len(fc.LineRanges) > 0 {
+			result = append(result, fc)
+		}
+	}
+	return result
+}
+
+// dropCommentRanges returns the changed ranges of a file that hold code
+func (g *Git) dropCommentRanges(file *pyscan.File, fc FileChanges) []LineRange {
+	ranges := make([]LineRange, 0, len(fc.LineRanges))
+	for _, r := range fc.LineRanges {
+		if !file.HasCode(r.Start, r.End) {
+			if g.verbose {
+				fmt.Fprintf(g.log, "Ignoring comment-only change in %s:%d-%d\n", fc.FilePath, r.Start, r.End)
+			}
+			continue
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
@@ -0,0 +1,116 @@
+package git
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// expandSource has a decorated class with a method and a module-level statement
+const expandSource = `@decorator
+class A:
+    def f(self):
+        x = call(
+            1,
+        )
+        return x
+
+    def g(self):
+        pass
+
+
+y = [
+    2,
+]
+`
+
+func TestExpandLineRanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		ranges    []LineRange
+		expansion Expansion
+		expected  []LineRange
+	}{
+		{name: "none", ranges: []LineRange{{Start: 5, End: 5}}, expansion: ExpandNone, expected: []LineRange{{Start: 5, End: 5}}},
+		{name: "statement", ranges: []LineRange{{Start: 5, End: 5}}, expansion: ExpandStatement, expected: []LineRange{{Start: 4, End: 6}}},
+		{name: "decorator", ranges: []LineRange{{Start: 2, End: 2}}, expansion: ExpandStatement, expected: []LineRange{{Start: 1, End: 2}}},
+		{name: "function", ranges: []LineRange{{Start: 5, End: 5}}, expansion: ExpandFunction, expected: []LineRange{{Start: 3, End: 7}}},
+		{name: "class", ranges: []LineRange{{Start: 10, End: 10}}, expansion: ExpandClass, expected: []LineRange{{Start: 1, End: 10}}},
+		{name: "outside a function", ranges: []LineRange{{Start: 14, End: 14}}, expansion: ExpandFunction, expected: []LineRange{{Start: 13, End: 15}}},
+		{name: "blank line", ranges: []LineRange{{Start: 11, End: 12}}, expansion: ExpandStatement, expected: []LineRange{{Start: 11, End: 12}}},
+		{
+			name:      "merges overlapping",
+			ranges:    []LineRange{{Start: 4, End: 4}, {Start: 7, End: 7}, {Start: 9, End: 9}},
+			expansion: ExpandFunction,
+			expected:  []LineRange{{Start: 3, End: 7}, {Start: 9, End: 10}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges := ExpandLineRanges([]byte(expandSource), tt.ranges, tt.expansion)
+			if !reflect.DeepEqual(ranges, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, ranges)
+			}
+		})
+	}
+}
+
+func TestWidenRangesExpansion(t *testing.T) {
+	g := &Git{seamPolicy: SeamOff, expansion: ExpandFunction}
+	changes := g.widenRanges([]FileChanges{
+		{FilePath: "a.py", LineRanges: []LineRange{{Start: 5, End: 5}}},
+		{FilePath: "a.ipynb", LineRanges: []LineRange{{Start: 5, End: 5}}},
+	}, func(string) ([]byte, error) {
+		return []byte(expandSource), nil
+	})
+
+	// Notebooks are not Python source and are left alone
+	expected := []FileChanges{
+		{FilePath: "a.py", LineRanges: []LineRange{{Start: 3, End: 7}}},
+		{FilePath: "a.ipynb", LineRanges: []LineRange{{Start: 5, End: 5}}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+}
+
+func TestWidenRangesNormalizes(t *testing.T) {
+	var log bytes.Buffer
+	g := &Git{seamPolicy: SeamOff, verbose: true, log: &log}
+	changes := g.widenRanges([]FileChanges{
+		{FilePath: "a.py", LineRanges: []LineRange{{Start: 8, End: 9}, {Start: 1, End: 3}, {Start: 2, End: 5}, {Start: 1, End: 3}, {Start: 6, End: 6}}},
+		{FilePath: "b.py", LineRanges: []LineRange{{Start: 5, End: 5}, {Start: 1, End: 2}}},
+		{FilePath: "c.ipynb", LineRanges: []LineRange{{Start: 3, End: 4}, {Start: 4, End: 6}}},
+	}, func(string) ([]byte, error) {
+		t.Fatal("Expected no file to be read")
+		return nil, nil
+	})
+
+	// With a merge gap of 0, only overlapping, duplicate and touching ranges are merged
+	expected := []FileChanges{
+		{FilePath: "a.py", LineRanges: []LineRange{{Start: 1, End: 6}, {Start: 8, End: 9}}},
+		{FilePath: "b.py", LineRanges: []LineRange{{Start: 1, End: 2}, {Start: 5, End: 5}}},
+		{FilePath: "c.ipynb", LineRanges: []LineRange{{Start: 3, End: 6}}},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+
+	// Sorting alone is not reported as a merge
+	expectedLog := "Merged 5 ranges of a.py into 2 (merge gap 0)\nMerged 2 ranges of c.ipynb into 1 (merge gap 0)\n"
+	if log.String() != expectedLog {
+		t.Errorf("Expected log %q, got %q", expectedLog, log.String())
+	}
+}
+
+func TestParseExpansion(t *testing.T) {
+	for _, expansion := range append([]Expansion{ExpandNone}, Expansions...) {
+		if got, err := ParseExpansion(string(expansion)); err != nil || got != expansion {
+			t.Errorf("ParseExpansion(%q): expected %q, got %q (%v)", expansion, expansion, got, err)
+		}
+	}
+	if _, err := ParseExpansion("module"); err == nil {
+		t.Error("Expected error for unknown expansion, got nil")
+	}
+}
@@ -1,10 +1,14 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -19,21 +23,92 @@ type LineRange struct {
 type FileChanges struct {
 	FilePath   string
 	LineRanges []LineRange
+	// OldPath is the path the file was renamed or copied from, if any. Line
+	// ranges then only cover the lines that differ from it.
+	OldPath string
+	// Seams are the new-file lines after which lines were deleted without
+	// replacement, 0 being the start of the file. The seam policy turns them
+	// into line ranges.
+	Seams []int
+}
+
+// DiffOptions configures how files are matched between the two sides of a diff
+// and which changes count
+type DiffOptions struct {
+	// Renames detects renamed files, so that moving a file doesn't report all of its lines
+	Renames bool
+	// Copies also detects files copied from a file that is modified or renamed
+	Copies bool
+	// Similarity is the percentage of a file that must be unchanged for it to
+	// count as renamed or copied
+	Similarity int
+	// IgnoreWhitespace ignores changes in whitespace, as git diff -w does, so
+	// re-indented lines are not reported
+	IgnoreWhitespace bool
+	// IgnoreBlankLines ignores lines that were only added or removed blank lines
+	IgnoreBlankLines bool
+	// IgnoreComments drops changed ranges of Python files whose new lines are
+	// only comments or blank
+	IgnoreComments bool
+}
+
+// DefaultDiffOptions detects renames with git's default similarity of 50%
+func DefaultDiffOptions() DiffOptions {
+	return DiffOptions{Renames: true, Similarity: 50}
+}
+
+// args returns the git diff arguments for the options
+func (o DiffOptions) args() []string {
+	var args []string
+	switch {
+	case o.Copies:
+		args = append(args, fmt.Sprintf("-C%d%%", o.Similarity))
+	case o.Renames:
+		args = append(args, fmt.Sprintf("-M%d%%", o.Similarity))
+	default:
+		args = append(args, "--no-renames")
+	}
+	if o.IgnoreWhitespace {
+		args = append(args, "--ignore-all-space")
+	}
+	if o.IgnoreBlankLines {
+		args = append(args, "--ignore-blank-lines")
+	}
+	return args
 }
 
 // Git provides Git operations
 type Git struct {
 	repoRoot string
 	verbose  bool
+	// filter selects the changed files that are reported
+	filter      func(path string) bool
+	diffOptions DiffOptions
+	seamPolicy  SeamPolicy
+	expansion   Expansion
+	mergeGap    int
+	// log receives progress messages in verbose mode
+	log io.Writer
 }
 
-// New creates a new Git instance
+// New creates a new Git instance for the repository containing the current directory
 func New(verbose bool) (*Git, error) {
-	g := &Git{verbose: verbose}
+	return NewAt("", verbose)
+}
+
+// NewAt creates a new Git instance for the repository containing dir. An empty
+// dir means the current directory. All operations run from the repository root,
+// so file paths are always relative to it.
+func NewAt(dir string, verbose bool) (*Git, error) {
+	g := &Git{verbose: verbose, filter: isPythonFile, diffOptions: DefaultDiffOptions(), seamPolicy: SeamOff, log: os.Stdout}
 
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd.Dir = dir
 	output, err := cmd.Output()
 	if err != nil {
+		if dir != "" {
+			return nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+		}
 		return nil, fmt.Errorf("not in a git repository: %w", err)
 	}
 
@@ -41,9 +116,74 @@ func New(verbose bool) (*Git, error) {
 	return g, nil
 }
 
+// command returns a git command that runs from the repository root
+func (g *Git) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.repoRoot
+	return cmd
+}
+
+// SetLog sets where progress messages are written. The default is stdout.
+func (g *Git) SetLog(log io.Writer) {
+	g.log = log
+}
+
+// SetFileFilter sets which changed files are reported, by their path relative to
+// the repository root. By default only Python files and notebooks are.
+func (g *Git) SetFileFilter(filter func(path string) bool) {
+	g.filter = filter
+}
+
+// SetDiffOptions sets how renamed and copied files are detected and which
+// changes are ignored. The similarity must be between 1 and 100, since git
+// takes 0 as its default of 50.
+func (g *Git) SetDiffOptions(opts DiffOptions) error {
+	if opts.Similarity < 1 || opts.Similarity > 100 {
+		return fmt.Errorf("similarity must be between 1 and 100, got %d", opts.Similarity)
+	}
+	g.diffOptions = opts
+	return nil
+}
+
+// SetMergeGap sets how many unchanged lines may separate two changed ranges of
+// a file for them to be merged into one. Zero only merges ranges that overlap or
+// touch.
+func (g *Git) SetMergeGap(gap int) error {
+	if gap < 0 {
+		return fmt.Errorf("merge gap must not be negative, got %d", gap)
+	}
+	g.mergeGap = gap
+	return nil
+}
+
+// NormalizeRanges returns ranges sorted by start, with duplicates removed and
+// ranges merged when they overlap or are separated by at most gap unchanged
+// lines. The input is not modified.
+func NormalizeRanges(ranges []LineRange, gap int) []LineRange {
+	sorted := append([]LineRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	normalized := make([]LineRange, 0, len(sorted))
+	for _, r := range sorted {
+		if n := len(normalized); n > 0 && r.Start <= normalized[n-1].End+gap+1 {
+			normalized[n-1].End = max(normalized[n-1].End, r.End)
+			continue
+		}
+		normalized = append(normalized, r)
+	}
+	return normalized
+}
+
+// selects reports whether a changed file is reported
+func (g *Git) selects(path string) bool {
+	return g.filter(path)
+}
+
 // GetCurrentBranch returns the current branch name
 func (g *Git) GetCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	cmd := g.command("rev-parse", "--abbrev-ref", "HEAD")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("failed to get current branch: %w", err)
@@ -55,7 +195,8 @@ func (g *Git) GetCurrentBranch() (string, error) {
 // including both tracked changes and untracked files
 func (g *Git) GetChangedFiles(baseBranch string) ([]string, error) {
 	// Get tracked changes
-	cmd := exec.Command("git", "diff", "--name-only", baseBranch)
+	args := append([]string{"diff", "--name-only"}, g.diffOptions.args()...)
+	cmd := g.command(append(args, baseBranch)...)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get changed files: %w", err)
@@ -65,23 +206,23 @@ func (g *Git) GetChangedFiles(baseBranch string) ([]string, error) {
 	if len(output) > 0 {
 		files := strings.Split(strings.TrimSpace(string(output)), "\n")
 		for _, file := range files {
-			if strings.HasSuffix(file, ".py") {
+			if g.selects(file) {
 				fileMap[file] = true
 			}
 		}
 	}
 
 	// Get untracked files
-	cmd = exec.Command("git", "ls-files", "--others", "--exclude-standard")
+	cmd = g.command("ls-files", "--others", "--exclude-standard")
 	output, err = cmd.Output()
 	if err != nil {
 		if g.verbose {
-			fmt.Printf("Warning: could not get untracked files: %v\n", err)
+			fmt.Fprintf(g.log, "Warning: could not get untracked files: %v\n", err)
 		}
 	} else if len(output) > 0 {
 		files := strings.Split(strings.TrimSpace(string(output)), "\n")
 		for _, file := range files {
-			if strings.HasSuffix(file, ".py") {
+			if g.selects(file) {
 				fileMap[file] = true
 			}
 		}
@@ -89,7 +230,7 @@ func (g *Git) GetChangedFiles(baseBranch string) ([]string, error) {
 
 	if len(fileMap) == 0 {
 		if g.verbose {
-			fmt.Println("No changed files found")
+			fmt.Fprintln(g.log, "No changed files found")
 		}
 		return []string{}, nil
 	}
@@ -108,50 +249,44 @@ func (g *Git) GetRepoRoot() string {
 	return g.repoRoot
 }
 
-// GetChangedLineRanges returns the changed line ranges for each Python file
-func (g *Git) GetChangedLineRanges(baseBranch string) ([]FileChanges, error) {
-	changedFiles, err := g.GetChangedFiles(baseBranch)
+// HooksDir returns the directory git runs hooks from, which is core.hooksPath
+// when it is set and .git/hooks otherwise
+func (g *Git) HooksDir() (string, error) {
+	output, err := g.command("rev-parse", "--git-path", "hooks").Output()
 	if err != nil {
-		return nil, err
+		return "", fmt.Errorf("failed to find hooks directory: %w", err)
 	}
 
-	if len(changedFiles) == 0 {
-		if g.verbose {
-			fmt.Println("No changed files found")
-		}
-		return []FileChanges{}, nil
+	dir := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(g.repoRoot, dir)
 	}
+	return dir, nil
+}
 
-	var fileChangesList []FileChanges
-
-	for _, file := range changedFiles {
-		ranges, err := g.getFileLineRanges(baseBranch, file)
-		if err != nil {
-			if g.verbose {
-				fmt.Printf("Warning: Could not get line ranges for %s: %v\n", file, err)
-			}
-			continue
-		}
+// GetChangedLineRanges returns the changed line ranges for each Python file,
+// including untracked files, which are covered in full unless they were moved
+// from a tracked file. All files are read from a single git diff.
+func (g *Git) GetChangedLineRanges(baseBranch string) ([]FileChanges, error) {
+	fileChangesList, err := g.diffWorkingTreeLineRanges(baseBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changed lines against %s: %w", baseBranch, err)
+	}
+	fileChangesList = g.widenRanges(fileChangesList, g.readWorkingTreeFile)
 
-		if len(ranges) > 0 {
-			fileChangesList = append(fileChangesList, FileChanges{
-				FilePath:   file,
-				LineRanges: ranges,
-			})
+	if len(fileChangesList) == 0 {
+		if g.verbose {
+			fmt.Fprintln(g.log, "No changed files found")
 		}
+		return []FileChanges{}, nil
 	}
 
 	return fileChangesList, nil
 }
 
-// isFileUntracked checks if a file is untracked (not in git index)
-func (g *Git) isFileUntracked(filePath string) (bool, error) {
-	cmd := exec.Command("git", "ls-files", "--others", "--exclude-standard", filePath)
-	output, err := cmd.Output()
-	if err != nil {
-		return false, err
-	}
-	return len(output) > 0, nil
+// isPythonFile reports whether path is a Python source file or a Jupyter notebook
+func isPythonFile(path string) bool {
+	return strings.HasSuffix(path, ".py") || strings.HasSuffix(path, ".ipynb")
 }
 
 // getFileLineCount returns the total number of lines in a file
@@ -167,44 +302,75 @@ func getFileLineCount(filePath string) (int, error) {
 	return lines, nil
 }
 
-// getFileLineRanges extracts the changed line ranges for a single file using git diff
-// For untracked files, returns the entire file range
-func (g *Git) getFileLineRanges(baseBranch, filePath string) ([]LineRange, error) {
-	// Check if file is untracked
-	untracked, err := g.isFileUntracked(filePath)
+// GetStagedLineRanges returns the changed line ranges for each staged Python file.
+// Line numbers refer to the content in the index, not the working tree.
+func (g *Git) GetStagedLineRanges() ([]FileChanges, error) {
+	fileChangesList, err := g.diffLineRanges("--cached")
 	if err != nil {
-		if g.verbose {
-			fmt.Printf("Warning: Could not determine if %s is untracked: %v\n", filePath, err)
-		}
+		return nil, fmt.Errorf("failed to get staged changes: %w", err)
 	}
+	fileChangesList = g.widenRanges(fileChangesList, g.ReadIndexFile)
 
-	if untracked {
-		// For untracked files, format the entire file
-		lineCount, err := getFileLineCount(filePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to count lines in %s: %w", filePath, err)
-		}
-		if lineCount == 0 {
-			return []LineRange{}, nil
-		}
-		return []LineRange{{Start: 1, End: lineCount}}, nil
+	if len(fileChangesList) == 0 && g.verbose {
+		fmt.Fprintln(g.log, "No staged files found")
 	}
 
-	// For tracked files, use git diff to find changed lines
-	cmd := exec.Command("git", "diff", baseBranch, "--", filePath)
+	return fileChangesList, nil
+}
+
+// ReadIndexFile returns the content of a file as it is staged in the index
+func (g *Git) ReadIndexFile(filePath string) ([]byte, error) {
+	cmd := g.command("cat-file", "blob", ":"+filePath)
 	output, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get diff for %s: %w", filePath, err)
+		return nil, fmt.Errorf("failed to read staged content of %s: %w", filePath, err)
 	}
+	return output, nil
+}
 
-	return parseUnifiedDiff(string(output))
+// WriteIndexFile stores content as a new blob and points the index entry of
+// filePath at it, keeping the entry's file mode. The working tree is not touched.
+func (g *Git) WriteIndexFile(filePath string, content []byte) error {
+	cmd := g.command("ls-files", "--stage", "--", filePath)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to read index entry for %s: %w", filePath, err)
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return fmt.Errorf("%s is not in the index", filePath)
+	}
+	mode := fields[0]
+
+	cmd = g.command("hash-object", "-w", "--stdin", "--no-filters")
+	cmd.Stdin = bytes.NewReader(content)
+	output, err = cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to write blob for %s: %w", filePath, err)
+	}
+	sha := strings.TrimSpace(string(output))
+
+	cmd = g.command("update-index", "--cacheinfo", mode+","+sha+","+filePath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update index for %s: %w: %s", filePath, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
 }
 
 // parseUnifiedDiff parses unified diff format and extracts changed line ranges.
 // It identifies line ranges that contain additions in the new file.
 func parseUnifiedDiff(diff string) ([]LineRange, error) {
+	ranges, _, err := parseHunks(diff)
+	return ranges, err
+}
+
+// parseHunks is parseUnifiedDiff that also returns the seams of the hunks that
+// only delete lines: the new-file line after which the lines were deleted
+func parseHunks(diff string) ([]LineRange, []int, error) {
 	lines := strings.Split(diff, "\n")
 	ranges := []LineRange{}
+	var seams []int
 
 	hunkHeaderRegex := regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
 
@@ -224,11 +390,16 @@ func parseUnifiedDiff(diff string) ([]LineRange, error) {
 
 			newStartLine, err := strconv.Atoi(match[1])
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			currentNewLine = newStartLine
 			changeRangeStart = 0
 
+			// A hunk without new lines starts at the line before the deletion
+			if match[2] == "0" {
+				seams = append(seams, newStartLine)
+			}
+
 			continue
 		}
 
@@ -259,5 +430,5 @@ func parseUnifiedDiff(diff string) ([]LineRange, error) {
 	}
 
 	finalizeRange()
-	return ranges, nil
+	return ranges, seams, nil
 }
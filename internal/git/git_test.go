@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -510,3 +511,299 @@ func TestParseUnifiedDiffEndingWithAddedLines(t *testing.T) {
 		t.Errorf("Expected range [3, 4], got [%d, %d]", ranges[0].Start, ranges[0].End)
 	}
 }
+
+// Tests for staged mode
+
+// runGit runs a git command in dir and fails the test on error
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %v: %s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+// setupPythonRepo creates a repository with a committed main.py and changes cwd into it
+func setupPythonRepo(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	runGit(t, tmpDir, "init")
+	runGit(t, tmpDir, "config", "user.email", "test@example.com")
+	runGit(t, tmpDir, "config", "user.name", "Test User")
+
+	content := "a = 1\n\n\n\n\n\n\n\n\n\nb = 2\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	runGit(t, tmpDir, "add", "main.py")
+	runGit(t, tmpDir, "commit", "-m", "Initial commit")
+
+	oldCwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(oldCwd) })
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+
+	return tmpDir
+}
+
+func TestGetStagedLineRangesIgnoresUnstagedHunks(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+	pyFile := filepath.Join(tmpDir, "main.py")
+
+	// Stage a change on line 1 only
+	if err := os.WriteFile(pyFile, []byte("a=1\n\n\n\n\n\n\n\n\n\nb = 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	runGit(t, tmpDir, "add", "main.py")
+
+	// Leave a change on line 11 unstaged
+	if err := os.WriteFile(pyFile, []byte("a=1\n\n\n\n\n\n\n\n\n\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	changes, err := g.GetStagedLineRanges()
+	if err != nil {
+		t.Fatalf("Failed to get staged line ranges: %v", err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 staged file, got %d", len(changes))
+	}
+	if len(changes[0].LineRanges) != 1 {
+		t.Fatalf("Expected 1 staged range, got %v", changes[0].LineRanges)
+	}
+	if changes[0].LineRanges[0] != (LineRange{Start: 1, End: 1}) {
+		t.Errorf("Expected range [1, 1], got %v", changes[0].LineRanges[0])
+	}
+}
+
+func TestWriteIndexFileKeepsWorkingTree(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+	pyFile := filepath.Join(tmpDir, "main.py")
+
+	working := "a = 1\n# unstaged\n"
+	if err := os.WriteFile(pyFile, []byte(working), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	staged := []byte("a = 1\nb = 2\n")
+	if err := g.WriteIndexFile("main.py", staged); err != nil {
+		t.Fatalf("Failed to write index file: %v", err)
+	}
+
+	content, err := g.ReadIndexFile("main.py")
+	if err != nil {
+		t.Fatalf("Failed to read index file: %v", err)
+	}
+	if string(content) != string(staged) {
+		t.Errorf("Expected staged content %q, got %q", staged, content)
+	}
+
+	onDisk, err := os.ReadFile(pyFile)
+	if err != nil {
+		t.Fatalf("Failed to read working tree file: %v", err)
+	}
+	if string(onDisk) != working {
+		t.Errorf("Expected working tree content %q, got %q", working, onDisk)
+	}
+}
+
+// Tests for running outside the repository root
+
+func TestChangedLineRangesFromSubdirectory(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+
+	// A modified tracked file and an untracked file, both at the repository root
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte("a = 1\n\n\n\n\n\n\n\n\n\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "new.py"), []byte("x = 1\ny = 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	subDir := filepath.Join(tmpDir, "pkg", "sub")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	if err := os.Chdir(subDir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	changes, err := g.GetChangedLineRanges("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to get changed line ranges: %v", err)
+	}
+
+	got := make(map[string][]LineRange)
+	for _, fc := range changes {
+		got[fc.FilePath] = fc.LineRanges
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 changed files, got %v", got)
+	}
+	if r := got["main.py"]; len(r) != 1 || r[0] != (LineRange{Start: 11, End: 11}) {
+		t.Errorf("Expected main.py range [11, 11], got %v", r)
+	}
+	if r := got["new.py"]; len(r) != 1 || r[0] != (LineRange{Start: 1, End: 2}) {
+		t.Errorf("Expected new.py range [1, 2], got %v", r)
+	}
+}
+
+func TestNewAtOutsideRepository(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte("a=1\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	// Run from a directory that is not inside any repository
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+
+	g, err := NewAt(tmpDir, false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	branch, err := g.GetCurrentBranch()
+	if err != nil || branch == "" {
+		t.Errorf("Expected current branch, got %q (%v)", branch, err)
+	}
+
+	changes, err := g.GetChangedLineRanges("HEAD")
+	if err != nil {
+		t.Fatalf("Failed to get changed line ranges: %v", err)
+	}
+	if len(changes) != 1 || changes[0].FilePath != "main.py" {
+		t.Errorf("Expected main.py to be changed, got %v", changes)
+	}
+}
+
+func TestNewAtNotARepository(t *testing.T) {
+	if _, err := NewAt(t.TempDir(), false); err == nil {
+		t.Errorf("Expected error for a directory outside any repository, got nil")
+	}
+}
+
+func TestIsPythonFile(t *testing.T) {
+	tests := map[string]bool{
+		"main.py":             true,
+		"pkg/analysis.ipynb":  true,
+		"readme.txt":          false,
+		"notebook.ipynb.orig": false,
+		"scripts/py":          false,
+	}
+	for path, expected := range tests {
+		if got := isPythonFile(path); got != expected {
+			t.Errorf("isPythonFile(%q) = %v, want %v", path, got, expected)
+		}
+	}
+}
+
+func TestHooksDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	runGit(t, tmpDir, "init")
+
+	g, err := NewAt(tmpDir, false)
+	if err != nil {
+		t.Fatalf("NewAt failed: %v", err)
+	}
+
+	dir, err := g.HooksDir()
+	if err != nil {
+		t.Fatalf("HooksDir failed: %v", err)
+	}
+	if expected := filepath.Join(g.GetRepoRoot(), ".git", "hooks"); dir != expected {
+		t.Errorf("HooksDir() = %q, want %q", dir, expected)
+	}
+
+	runGit(t, tmpDir, "config", "core.hooksPath", ".githooks")
+	dir, err = g.HooksDir()
+	if err != nil {
+		t.Fatalf("HooksDir failed: %v", err)
+	}
+	if expected := filepath.Join(g.GetRepoRoot(), ".githooks"); dir != expected {
+		t.Errorf("HooksDir() with core.hooksPath = %q, want %q", dir, expected)
+	}
+}
+
+func TestNormalizeRanges(t *testing.T) {
+	ranges := []LineRange{{Start: 10, End: 12}, {Start: 1, End: 2}, {Start: 3, End: 4}, {Start: 1, End: 2}, {Start: 11, End: 15}, {Start: 18, End: 18}, {Start: 25, End: 25}}
+
+	tests := []struct {
+		gap      int
+		expected []LineRange
+	}{
+		{gap: 0, expected: []LineRange{{Start: 1, End: 4}, {Start: 10, End: 15}, {Start: 18, End: 18}, {Start: 25, End: 25}}},
+		{gap: 2, expected: []LineRange{{Start: 1, End: 4}, {Start: 10, End: 18}, {Start: 25, End: 25}}},
+		{gap: 5, expected: []LineRange{{Start: 1, End: 18}, {Start: 25, End: 25}}},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeRanges(ranges, tt.gap); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("NormalizeRanges(gap %d): expected %v, got %v", tt.gap, tt.expected, got)
+		}
+	}
+
+	// The input keeps its order
+	if ranges[0].Start != 10 {
+		t.Errorf("Expected input to be left unsorted, got %v", ranges)
+	}
+}
+
+func TestSetMergeGap(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+
+	// Two changes separated by two unchanged lines
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte("a = 2\n\n\nb = 3\n\n\n\n\n\n\nb = 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+	if err := g.SetMergeGap(-1); err == nil {
+		t.Error("Expected error for negative merge gap, got nil")
+	}
+
+	for gap, expected := range map[int][]LineRange{
+		1: {{Start: 1, End: 1}, {Start: 4, End: 4}},
+		2: {{Start: 1, End: 4}},
+	} {
+		if err := g.SetMergeGap(gap); err != nil {
+			t.Fatalf("SetMergeGap(%d) failed: %v", gap, err)
+		}
+		changes, err := g.GetChangedLineRanges("HEAD")
+		if err != nil {
+			t.Fatalf("Failed to get changed line ranges: %v", err)
+		}
+		if len(changes) != 1 || !reflect.DeepEqual(changes[0].LineRanges, expected) {
+			t.Errorf("Gap %d: expected ranges %v, got %+v", gap, expected, changes)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// RevisionRange describes the span of history to compute changes for.
+// An empty To compares From against the working tree.
+type RevisionRange struct {
+	From string
+	To   string
+	// ThreeDot compares To against the merge base of From and To,
+	// so only changes introduced on the To side are reported
+	ThreeDot bool
+}
+
+// ParseRevisionRange parses a revision range such as "A..B", "A...B" or a single
+// revision "A". A missing side of "A..", "..B" or "A...B" defaults to HEAD, as in git.
+func ParseRevisionRange(spec string) (RevisionRange, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return RevisionRange{}, fmt.Errorf("empty revision range")
+	}
+
+	var rr RevisionRange
+	var sep string
+	if strings.Contains(spec, "...") {
+		sep = "..."
+		rr.ThreeDot = true
+	} else if strings.Contains(spec, "..") {
+		sep = ".."
+	}
+
+	if sep == "" {
+		rr.From = spec
+		return rr, nil
+	}
+
+	parts := strings.SplitN(spec, sep, 2)
+	rr.From, rr.To = parts[0], parts[1]
+	if strings.Contains(rr.To, "..") {
+		return RevisionRange{}, fmt.Errorf("invalid revision range %q", spec)
+	}
+	if rr.From == "" {
+		rr.From = "HEAD"
+	}
+	if rr.To == "" {
+		rr.To = "HEAD"
+	}
+
+	return rr, nil
+}
+
+// String returns the range in git's revision range syntax
+func (rr RevisionRange) String() string {
+	switch {
+	case rr.To == "":
+		return rr.From
+	case rr.ThreeDot:
+		return rr.From + "..." + rr.To
+	default:
+		return rr.From + ".." + rr.To
+	}
+}
+
+// diffArgs returns the revision arguments to pass to git diff
+func (rr RevisionRange) diffArgs() []string {
+	switch {
+	case rr.To == "":
+		return []string{rr.From}
+	case rr.ThreeDot:
+		return []string{rr.From + "..." + rr.To}
+	default:
+		return []string{rr.From, rr.To}
+	}
+}
+
+// ResolveRevision returns the commit hash a revision points to
+func (g *Git) ResolveRevision(rev string) (string, error) {
+	cmd := g.command("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q: %w", rev, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// MergeBase returns the best common ancestor of two revisions
+func (g *Git) MergeBase(a, b string) (string, error) {
+	cmd := g.command("merge-base", a, b)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find merge base of %s and %s: %w", a, b, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// GetChangedLineRangesInRange returns the changed line ranges for each Python file
+// changed within a revision range. Line numbers refer to the content at To.
+func (g *Git) GetChangedLineRangesInRange(rr RevisionRange) ([]FileChanges, error) {
+	if rr.To == "" {
+		return g.GetChangedLineRanges(rr.From)
+	}
+
+	fileChangesList, err := g.diffLineRanges(rr.diffArgs()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get changed lines for %s: %w", rr, err)
+	}
+	fileChangesList = g.widenRanges(fileChangesList, func(path string) ([]byte, error) {
+		return g.ReadRevisionFile(rr.To, path)
+	})
+
+	if len(fileChangesList) == 0 && g.verbose {
+		fmt.Fprintln(g.log, "No changed files found")
+	}
+
+	return fileChangesList, nil
+}
+
+// ReadRevisionFile returns the content of a file at a revision
+func (g *Git) ReadRevisionFile(rev, filePath string) ([]byte, error) {
+	output, err := g.command("cat-file", "blob", rev+":"+filePath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", filePath, rev, err)
+	}
+	return output, nil
+}
+
+// HasUncommittedChanges reports whether any of the given files differ between
+// the working tree and HEAD, staged or not
+func (g *Git) HasUncommittedChanges(filePaths []string) (bool, error) {
+	if len(filePaths) == 0 {
+		return false, nil
+	}
+
+	args := append([]string{"diff", "--quiet", "HEAD", "--"}, filePaths...)
+	err := g.command(args...).Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		return true, nil
+	default:
+		return false, fmt.Errorf("failed to compare files with HEAD: %w", err)
+	}
+}
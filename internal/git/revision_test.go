@@ -0,0 +1,225 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRevisionRange(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected RevisionRange
+		wantErr  bool
+	}{
+		{"main", RevisionRange{From: "main"}, false},
+		{"HEAD~1..HEAD", RevisionRange{From: "HEAD~1", To: "HEAD"}, false},
+		{"origin/main...HEAD", RevisionRange{From: "origin/main", To: "HEAD", ThreeDot: true}, false},
+		{"v1.0..v2.0", RevisionRange{From: "v1.0", To: "v2.0"}, false},
+		{"main..", RevisionRange{From: "main", To: "HEAD"}, false},
+		{"...feature", RevisionRange{From: "HEAD", To: "feature", ThreeDot: true}, false},
+		{"", RevisionRange{}, true},
+		{"a..b..c", RevisionRange{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			rr, err := ParseRevisionRange(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRevisionRange(%q): expected error, got %v", tt.spec, rr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRevisionRange(%q): unexpected error %v", tt.spec, err)
+			}
+			if rr != tt.expected {
+				t.Errorf("ParseRevisionRange(%q) = %+v, want %+v", tt.spec, rr, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRevisionRangeString(t *testing.T) {
+	tests := []struct {
+		rr       RevisionRange
+		expected string
+	}{
+		{RevisionRange{From: "main"}, "main"},
+		{RevisionRange{From: "a", To: "b"}, "a..b"},
+		{RevisionRange{From: "a", To: "b", ThreeDot: true}, "a...b"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rr.String(); got != tt.expected {
+			t.Errorf("%+v.String() = %q, want %q", tt.rr, got, tt.expected)
+		}
+	}
+}
+
+func TestGetChangedLineRangesInRangeIgnoresWorkingTree(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+	pyFile := filepath.Join(tmpDir, "main.py")
+
+	// Commit a change on line 11
+	if err := os.WriteFile(pyFile, []byte("a = 1\n\n\n\n\n\n\n\n\n\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	runGit(t, tmpDir, "commit", "-am", "Change b")
+
+	// Uncommitted noise on line 1 must not show up
+	if err := os.WriteFile(pyFile, []byte("a=1\n\n\n\n\n\n\n\n\n\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	changes, err := g.GetChangedLineRangesInRange(RevisionRange{From: "HEAD~1", To: "HEAD"})
+	if err != nil {
+		t.Fatalf("Failed to get line ranges: %v", err)
+	}
+
+	if len(changes) != 1 || len(changes[0].LineRanges) != 1 {
+		t.Fatalf("Expected a single range in one file, got %v", changes)
+	}
+	if changes[0].LineRanges[0] != (LineRange{Start: 11, End: 11}) {
+		t.Errorf("Expected range [11, 11], got %v", changes[0].LineRanges[0])
+	}
+}
+
+func TestGetChangedLineRangesInRangeThreeDot(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+	runGit(t, tmpDir, "branch", "-M", "main")
+	runGit(t, tmpDir, "checkout", "-b", "feature")
+
+	// The feature branch changes line 11
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte("a = 1\n\n\n\n\n\n\n\n\n\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	runGit(t, tmpDir, "commit", "-am", "Feature change")
+
+	// main moves ahead with a change to another file
+	runGit(t, tmpDir, "checkout", "main")
+	if err := os.WriteFile(filepath.Join(tmpDir, "other.py"), []byte("x = 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	runGit(t, tmpDir, "add", "other.py")
+	runGit(t, tmpDir, "commit", "-m", "Main change")
+	runGit(t, tmpDir, "checkout", "feature")
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	changes, err := g.GetChangedLineRangesInRange(RevisionRange{From: "main", To: "HEAD", ThreeDot: true})
+	if err != nil {
+		t.Fatalf("Failed to get line ranges: %v", err)
+	}
+
+	if len(changes) != 1 || changes[0].FilePath != "main.py" {
+		t.Fatalf("Expected only main.py to be reported, got %v", changes)
+	}
+}
+
+func TestResolveRevision(t *testing.T) {
+	setupPythonRepo(t)
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	if _, err := g.ResolveRevision("HEAD"); err != nil {
+		t.Errorf("Expected HEAD to resolve, got %v", err)
+	}
+	if _, err := g.ResolveRevision("does-not-exist"); err == nil {
+		t.Errorf("Expected error for unknown revision")
+	}
+}
+
+func TestMergeBaseExcludesBaseBranchChanges(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+	pyFile := filepath.Join(tmpDir, "main.py")
+	runGit(t, tmpDir, "branch", "-M", "main")
+	runGit(t, tmpDir, "checkout", "-b", "feature")
+
+	// The feature branch changes line 11
+	if err := os.WriteFile(pyFile, []byte("a = 1\n\n\n\n\n\n\n\n\n\nb=2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	runGit(t, tmpDir, "commit", "-am", "Feature change")
+
+	// main moves ahead with a change on line 1 of the same file
+	runGit(t, tmpDir, "checkout", "main")
+	if err := os.WriteFile(pyFile, []byte("a=1\n\n\n\n\n\n\n\n\n\nb = 2\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	runGit(t, tmpDir, "commit", "-am", "Main change")
+	runGit(t, tmpDir, "checkout", "feature")
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	mergeBase, err := g.MergeBase("main", "HEAD")
+	if err != nil {
+		t.Fatalf("Failed to get merge base: %v", err)
+	}
+
+	changes, err := g.GetChangedLineRanges(mergeBase)
+	if err != nil {
+		t.Fatalf("Failed to get line ranges: %v", err)
+	}
+	if len(changes) != 1 || len(changes[0].LineRanges) != 1 || changes[0].LineRanges[0] != (LineRange{Start: 11, End: 11}) {
+		t.Errorf("Expected only the feature change on line 11, got %v", changes)
+	}
+
+	// Diffing against the tip of main also reports main's change on line 1
+	tipChanges, err := g.GetChangedLineRanges("main")
+	if err != nil {
+		t.Fatalf("Failed to get line ranges: %v", err)
+	}
+	if len(tipChanges) != 1 || len(tipChanges[0].LineRanges) != 2 {
+		t.Errorf("Expected two ranges when diffing against the branch tip, got %v", tipChanges)
+	}
+}
+
+func TestHasUncommittedChanges(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	if dirty, err := g.HasUncommittedChanges([]string{"main.py"}); err != nil || dirty {
+		t.Errorf("Expected a clean file, got %v (%v)", dirty, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.py"), []byte("a=1\n"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if dirty, err := g.HasUncommittedChanges([]string{"main.py"}); err != nil || !dirty {
+		t.Errorf("Expected an uncommitted change, got %v (%v)", dirty, err)
+	}
+
+	// The staged change still differs from HEAD
+	runGit(t, tmpDir, "add", "main.py")
+	if dirty, err := g.HasUncommittedChanges([]string{"main.py"}); err != nil || !dirty {
+		t.Errorf("Expected a staged change, got %v (%v)", dirty, err)
+	}
+
+	content, err := g.ReadRevisionFile("HEAD", "main.py")
+	if err != nil {
+		t.Fatalf("Failed to read main.py at HEAD: %v", err)
+	}
+	if string(content) == "a=1\n" {
+		t.Error("Expected the committed content, got the working tree")
+	}
+}
@@ -0,0 +1,77 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/pyscan"
+)
+
+// SeamPolicy sets which lines are formatted where lines were deleted without
+// replacement, since the lines around a deletion may need reformatting, e.g.
+// after removing an argument from a call that spans several lines
+type SeamPolicy string
+
+// Seam policies
+const (
+	// SeamOff formats nothing for deletions
+	SeamOff SeamPolicy = "off"
+	// SeamLine formats the lines just before and after a deletion
+	SeamLine SeamPolicy = "line"
+	// SeamStatement formats the whole statements around a deletion
+	SeamStatement SeamPolicy = "statement"
+)
+
+// SeamPolicies are the available seam policies
+var SeamPolicies = []SeamPolicy{SeamOff, SeamLine, SeamStatement}
+
+// ParseSeamPolicy returns the seam policy with the given name
+func ParseSeamPolicy(name string) (SeamPolicy, error) {
+	for _, policy := range SeamPolicies {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+	names := make([]string, len(SeamPolicies))
+	for i, policy := range SeamPolicies {
+		names[i] = string(policy)
+	}
+	return "", fmt.Errorf("unknown seam policy %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// SetSeamPolicy sets which lines are reported around deletions. The default is SeamOff.
+func (g *Git) SetSeamPolicy(policy SeamPolicy) {
+	g.seamPolicy = policy
+}
+
+// seamLineRanges returns the lines to format around each seam: the lines just
+// before and after it, widened to their statements with SeamStatement
+func seamLineRanges(file *pyscan.File, seams []int, policy SeamPolicy) []LineRange {
+	var ranges []LineRange
+	for _, seam := range seams {
+		start := max(seam, 1)
+		end := min(seam+1, file.LineCount())
+		if start > end {
+			continue
+		}
+
+		if policy == SeamStatement {
+			for _, line := range []int{start, end} {
+				if stmt, ok := file.StatementAt(line); ok {
+					start = min(start, stmt.Start)
+					end = max(end, stmt.End)
+				}
+			}
+		}
+
+		ranges = append(ranges, LineRange{Start: start, End: end})
+	}
+	return ranges
+}
+
+// readWorkingTreeFile returns the content of a file in the working tree
+func (g *Git) readWorkingTreeFile(filePath string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(g.repoRoot, filePath))
+}
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseHunksSeams(t *testing.T) {
+	// Deletions after line 3 and at the start of the file, and a replacement,
+	// which is an added range rather than a seam
+	diff := "@@ -4,2 +3,0 @@\n-x\n-y\n@@ -1 +0,0 @@\n-z\n@@ -9 +8 @@\n-old\n+new"
+	ranges, seams, err := parseHunks(diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if expected := []LineRange{{Start: 8, End: 8}}; !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("Expected ranges %v, got %v", expected, ranges)
+	}
+	if expected := []int{3, 0}; !reflect.DeepEqual(seams, expected) {
+		t.Errorf("Expected seams %v, got %v", expected, seams)
+	}
+}
+
+func TestParseMultiFileDiffDeletionOnly(t *testing.T) {
+	diff := `diff --git a/a.py b/a.py
+index 1111111..2222222 100644
+--- a/a.py
++++ b/a.py
+@@ -3 +2,0 @@
+-    b,
+`
+	changes, err := parseMultiFileDiff(diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []FileChanges{{FilePath: "a.py", LineRanges: []LineRange{}, Seams: []int{2}}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+}
+
+func TestSeamLineRanges(t *testing.T) {
+	content := []byte("x = 1\ncall(\n    a,\n    c,\n)\ny = 2\n")
+
+	tests := []struct {
+		name     string
+		seams    []int
+		policy   SeamPolicy
+		expected []LineRange
+	}{
+		{name: "line", seams: []int{3}, policy: SeamLine, expected: []LineRange{{Start: 3, End: 4}}},
+		{name: "statement", seams: []int{3}, policy: SeamStatement, expected: []LineRange{{Start: 2, End: 5}}},
+		{name: "statement between statements", seams: []int{1}, policy: SeamStatement, expected: []LineRange{{Start: 1, End: 5}}},
+		{name: "start of file", seams: []int{0}, policy: SeamLine, expected: []LineRange{{Start: 1, End: 1}}},
+		{name: "end of file", seams: []int{6}, policy: SeamLine, expected: []LineRange{{Start: 6, End: 6}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Git{seamPolicy: tt.policy}
+			changes := g.widenRanges([]FileChanges{{FilePath: "a.py", LineRanges: []LineRange{}, Seams: tt.seams}}, func(string) ([]byte, error) {
+				return content, nil
+			})
+			if len(changes) != 1 || !reflect.DeepEqual(changes[0].LineRanges, tt.expected) {
+				t.Errorf("Expected ranges %v, got %+v", tt.expected, changes)
+			}
+		})
+	}
+}
+
+func TestWidenRangesSeamsOff(t *testing.T) {
+	g := &Git{seamPolicy: SeamOff}
+	changes := g.widenRanges([]FileChanges{
+		{FilePath: "deleted.py", LineRanges: []LineRange{}, Seams: []int{1}},
+		{FilePath: "added.py", LineRanges: []LineRange{{Start: 1, End: 1}}, Seams: []int{4}},
+	}, func(string) ([]byte, error) {
+		t.Fatal("Expected no file to be read")
+		return nil, nil
+	})
+
+	expected := []FileChanges{{FilePath: "added.py", LineRanges: []LineRange{{Start: 1, End: 1}}, Seams: []int{4}}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v, got %v", expected, changes)
+	}
+}
+
+func TestGetChangedLineRangesSeams(t *testing.T) {
+	tmpDir := setupPythonRepo(t)
+
+	content := "x = 1\ncall(\n    a,\n    b,\n    c,\n)\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "call.py"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	runGit(t, tmpDir, "add", "call.py")
+	runGit(t, tmpDir, "commit", "-m", "Add call.py")
+
+	// Remove an argument in a new commit
+	edited := "x = 1\ncall(\n    a,\n    c,\n)\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "call.py"), []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	runGit(t, tmpDir, "add", "call.py")
+	runGit(t, tmpDir, "commit", "-m", "Remove b")
+
+	g, err := New(false)
+	if err != nil {
+		t.Fatalf("Failed to create Git instance: %v", err)
+	}
+
+	tests := []struct {
+		policy   SeamPolicy
+		expected []FileChanges
+	}{
+		{policy: SeamOff, expected: nil},
+		{policy: SeamLine, expected: []FileChanges{{FilePath: "call.py", LineRanges: []LineRange{{Start: 3, End: 4}}, Seams: []int{3}}}},
+		{policy: SeamStatement, expected: []FileChanges{{FilePath: "call.py", LineRanges: []LineRange{{Start: 2, End: 5}}, Seams: []int{3}}}},
+	}
+
+	for _, tt := range tests {
+		g.SetSeamPolicy(tt.policy)
+
+		changes, err := g.GetChangedLineRangesInRange(RevisionRange{From: "HEAD~1", To: "HEAD"})
+		if err != nil {
+			t.Fatalf("Failed to get changed line ranges: %v", err)
+		}
+		if !reflect.DeepEqual(changes, tt.expected) {
+			t.Errorf("%s: expected %+v, got %+v", tt.policy, tt.expected, changes)
+		}
+
+		// The working tree matches HEAD
+		changes, err = g.GetChangedLineRanges("HEAD~1")
+		if err != nil {
+			t.Fatalf("Failed to get changed line ranges: %v", err)
+		}
+		if len(changes) != len(tt.expected) || len(changes) > 0 && !reflect.DeepEqual(changes, tt.expected) {
+			t.Errorf("%s: expected %+v against the working tree, got %+v", tt.policy, tt.expected, changes)
+		}
+	}
+}
+
+func TestParseSeamPolicy(t *testing.T) {
+	for _, policy := range SeamPolicies {
+		if got, err := ParseSeamPolicy(string(policy)); err != nil || got != policy {
+			t.Errorf("ParseSeamPolicy(%q): expected %q, got %q (%v)", policy, policy, got, err)
+		}
+	}
+	if _, err := ParseSeamPolicy("block"); err == nil {
+		t.Error("Expected error for unknown policy, got nil")
+	}
+}
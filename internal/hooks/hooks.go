@@ -0,0 +1,203 @@
+// Package hooks installs git hooks that run ruff-format-changes, chaining any
+// hook that was installed before.
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Hooks that can be installed
+const (
+	PreCommit = "pre-commit"
+	PrePush   = "pre-push"
+)
+
+// Names are the hooks that can be installed
+var Names = []string{PreCommit, PrePush}
+
+// marker identifies a hook written by this package
+const marker = "# Managed by ruff-format-changes"
+
+// chainedSuffix is appended to the name of a hook that was installed before the
+// managed hook. The managed hook runs it first.
+const chainedSuffix = ".local"
+
+// Status describes a hook in the hooks directory
+type Status struct {
+	Name string
+	Path string
+	// Installed is true when the hook is managed by this package
+	Installed bool
+	// Foreign is true when another hook is installed instead
+	Foreign bool
+	// Chained is true when a hook installed before is run by the managed hook
+	Chained bool
+}
+
+// Install writes the managed hook into dir. An existing hook that is not managed
+// is renamed with the .local suffix and run first, so it keeps working. Installing
+// over a managed hook updates it. It reports whether an existing hook was chained.
+func Install(dir, name string) (bool, error) {
+	script, err := Script(name)
+	if err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+
+	status, err := GetStatus(dir, name)
+	if err != nil {
+		return false, err
+	}
+
+	chained := status.Chained
+	if status.Foreign {
+		chainedPath := status.Path + chainedSuffix
+		if _, err := os.Lstat(chainedPath); err == nil {
+			return false, fmt.Errorf("cannot chain existing %s hook: %s already exists", name, chainedPath)
+		}
+		if err := os.Rename(status.Path, chainedPath); err != nil {
+			return false, fmt.Errorf("failed to move existing %s hook: %w", name, err)
+		}
+		chained = true
+	}
+
+	if err := os.WriteFile(status.Path, []byte(script), 0755); err != nil {
+		return false, fmt.Errorf("failed to write %s hook: %w", name, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(status.Path, 0755); err != nil {
+		return false, fmt.Errorf("failed to make %s hook executable: %w", name, err)
+	}
+
+	return chained, nil
+}
+
+// Uninstall removes the managed hook from dir and puts back the hook it chained,
+// if any. A hook that is not managed is left alone. It reports whether a hook
+// was removed.
+func Uninstall(dir, name string) (bool, error) {
+	status, err := GetStatus(dir, name)
+	if err != nil {
+		return false, err
+	}
+	if !status.Installed {
+		return false, nil
+	}
+
+	if err := os.Remove(status.Path); err != nil {
+		return false, fmt.Errorf("failed to remove %s hook: %w", name, err)
+	}
+	if status.Chained {
+		if err := os.Rename(status.Path+chainedSuffix, status.Path); err != nil {
+			return true, fmt.Errorf("failed to restore previous %s hook: %w", name, err)
+		}
+	}
+	return true, nil
+}
+
+// GetStatus inspects the hook name in dir
+func GetStatus(dir, name string) (Status, error) {
+	status := Status{Name: name, Path: filepath.Join(dir, name)}
+
+	content, err := os.ReadFile(status.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return status, nil
+	}
+	if err != nil {
+		return status, fmt.Errorf("failed to read %s hook: %w", name, err)
+	}
+
+	if !strings.Contains(string(content), marker) {
+		status.Foreign = true
+		return status, nil
+	}
+
+	status.Installed = true
+	if _, err := os.Lstat(status.Path + chainedSuffix); err == nil {
+		status.Chained = true
+	}
+	return status, nil
+}
+
+// Script returns the managed script of a hook
+func Script(name string) (string, error) {
+	var script string
+	switch name {
+	case PreCommit:
+		script = preCommitScript
+	case PrePush:
+		script = prePushScript
+	default:
+		return "", fmt.Errorf("unknown hook %q (available: %s)", name, strings.Join(Names, ", "))
+	}
+	return strings.NewReplacer("{{marker}}", marker, "{{name}}", name, "{{chained}}", "$0"+chainedSuffix).Replace(script), nil
+}
+
+// scriptHeader introduces every managed hook
+const scriptHeader = `#!/bin/sh
+{{marker}}. Remove with: ruff-format-changes hooks uninstall
+# A {{name}} hook that existed before is kept next to this one with the
+# .local suffix and runs first.
+`
+
+// checkInstalled fails the hook when the tool is not on PATH
+const checkInstalled = `
+if ! command -v ruff-format-changes >/dev/null 2>&1; then
+	echo "ruff-format-changes: command not found; install it or run 'ruff-format-changes hooks uninstall'" >&2
+	exit 1
+fi
+`
+
+// preCommitScript formats the staged lines and re-stages them. A formatter
+// error fails the commit.
+const preCommitScript = scriptHeader + `
+if [ -x "{{chained}}" ]; then
+	"{{chained}}" "$@" || exit $?
+fi
+` + checkInstalled + `
+exec ruff-format-changes --staged
+`
+
+// prePushScript checks the lines changed by the pushed commits. git passes one
+// line per pushed ref on stdin, which is read first so the chained hook gets a
+// copy. The commits of a ref the remote doesn't have yet, or that it rewrote,
+// are those on no remote-tracking branch. --check formats the files as they are
+// in the pushed commit, so the working tree and the checked out branch don't matter.
+const prePushScript = scriptHeader + `
+refs=$(cat)
+
+if [ -x "{{chained}}" ]; then
+	printf '%s\n' "$refs" | "{{chained}}" "$@" || exit $?
+fi
+` + checkInstalled + `
+status=0
+while read -r local_ref local_sha remote_ref remote_sha; do
+	case "$local_sha" in
+	*[!0]*) ;;
+	*) continue ;; # Deleting a remote ref
+	esac
+
+	if git cat-file -e "$remote_sha^{commit}" 2>/dev/null; then
+		from=$remote_sha
+	else
+		first=$(git rev-list --reverse "$local_sha" --not --remotes | head -n 1)
+		if [ -z "$first" ]; then
+			continue # Every commit is on the remote already
+		fi
+		# The parent of the first new commit, or the empty tree for a root commit
+		from=$(git rev-parse -q --verify "$first^") || from=$(git hash-object -t tree /dev/null)
+	fi
+
+	ruff-format-changes --check --range "$from..$local_sha" || status=1
+done <<EOF
+$refs
+EOF
+exit $status
+`
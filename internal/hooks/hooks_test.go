@@ -0,0 +1,261 @@
+package hooks
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/testutil"
+)
+
+// installFakeTool puts a fake ruff-format-changes on PATH that logs its
+// arguments and exits with the given code
+func installFakeTool(t *testing.T, exitCode string) string {
+	t.Helper()
+	logFile := filepath.Join(t.TempDir(), "calls.log")
+	testutil.InstallFakeTool(t, "ruff-format-changes", "echo \"$*\" >> "+logFile+"\nexit "+exitCode+"\n")
+	return logFile
+}
+
+// runHook runs a hook with stdin and returns its error
+func runHook(t *testing.T, path, stdin string) error {
+	t.Helper()
+	cmd := exec.Command(path)
+	cmd.Stdin = strings.NewReader(stdin)
+	return cmd.Run()
+}
+
+func readLog(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Failed to read log: %v", err)
+	}
+	return string(content)
+}
+
+func TestInstallPreCommit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hooks")
+	logFile := installFakeTool(t, "0")
+
+	chained, err := Install(dir, PreCommit)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if chained {
+		t.Errorf("Expected nothing to be chained")
+	}
+
+	status, err := GetStatus(dir, PreCommit)
+	if err != nil || !status.Installed || status.Chained || status.Foreign {
+		t.Errorf("Expected an installed hook, got %+v (err: %v)", status, err)
+	}
+
+	if err := runHook(t, status.Path, ""); err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+	if got := readLog(t, logFile); got != "--staged\n" {
+		t.Errorf("Expected the hook to run --staged, got %q", got)
+	}
+}
+
+func TestInstallPreCommitFailsCommit(t *testing.T) {
+	dir := t.TempDir()
+	installFakeTool(t, "1")
+
+	if _, err := Install(dir, PreCommit); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := runHook(t, filepath.Join(dir, PreCommit), ""); err == nil {
+		t.Errorf("Expected the hook to fail when formatting fails")
+	}
+}
+
+func TestInstallChainsExistingHook(t *testing.T) {
+	dir := t.TempDir()
+	logFile := installFakeTool(t, "0")
+
+	existing := "#!/bin/sh\necho existing >> " + logFile + "\n"
+	hookPath := filepath.Join(dir, PreCommit)
+	if err := os.WriteFile(hookPath, []byte(existing), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+
+	status, _ := GetStatus(dir, PreCommit)
+	if !status.Foreign {
+		t.Errorf("Expected the existing hook to be reported, got %+v", status)
+	}
+
+	chained, err := Install(dir, PreCommit)
+	if err != nil || !chained {
+		t.Fatalf("Expected the existing hook to be chained, got %v (err: %v)", chained, err)
+	}
+
+	// Installing again updates the managed hook and keeps the chain
+	chained, err = Install(dir, PreCommit)
+	if err != nil || !chained {
+		t.Fatalf("Expected reinstalling to keep the chain, got %v (err: %v)", chained, err)
+	}
+
+	if err := runHook(t, hookPath, ""); err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+	if got := readLog(t, logFile); got != "existing\n--staged\n" {
+		t.Errorf("Expected the existing hook to run first, got %q", got)
+	}
+
+	removed, err := Uninstall(dir, PreCommit)
+	if err != nil || !removed {
+		t.Fatalf("Expected the hook to be removed, got %v (err: %v)", removed, err)
+	}
+	if content, _ := os.ReadFile(hookPath); string(content) != existing {
+		t.Errorf("Expected the existing hook to be restored, got %q", content)
+	}
+	if _, err := os.Stat(hookPath + chainedSuffix); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be gone, got %v", hookPath+chainedSuffix, err)
+	}
+}
+
+func TestInstallChainedHookFails(t *testing.T) {
+	dir := t.TempDir()
+	logFile := installFakeTool(t, "0")
+
+	if err := os.WriteFile(filepath.Join(dir, PreCommit), []byte("#!/bin/sh\nexit 3\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+	if _, err := Install(dir, PreCommit); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	err := runHook(t, filepath.Join(dir, PreCommit), "")
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("Expected the chained hook's exit code 3, got %v", err)
+	}
+	if got := readLog(t, logFile); got != "" {
+		t.Errorf("Expected formatting to be skipped, got %q", got)
+	}
+}
+
+func TestInstallChainConflict(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{PreCommit, PreCommit + chainedSuffix} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatalf("Failed to write hook: %v", err)
+		}
+	}
+
+	if _, err := Install(dir, PreCommit); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected chain conflict error, got %v", err)
+	}
+}
+
+func TestUninstallLeavesForeignHook(t *testing.T) {
+	dir := t.TempDir()
+	hookPath := filepath.Join(dir, PreCommit)
+	if err := os.WriteFile(hookPath, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+
+	removed, err := Uninstall(dir, PreCommit)
+	if err != nil || removed {
+		t.Errorf("Expected nothing to be removed, got %v (err: %v)", removed, err)
+	}
+	if _, err := os.Stat(hookPath); err != nil {
+		t.Errorf("Expected the hook to be kept, got %v", err)
+	}
+}
+
+// setupRepo creates a repository with a commit per message, changes into it and
+// returns the hashes of the commits
+func setupRepo(t *testing.T, messages ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(originalDir) })
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+
+	git := func(args ...string) string {
+		t.Helper()
+		output, err := exec.Command("git", args...).Output()
+		if err != nil {
+			t.Fatalf("git %s failed: %v", strings.Join(args, " "), err)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	git("init", "-q")
+	git("config", "user.email", "test@example.com")
+	git("config", "user.name", "Test User")
+
+	var commits []string
+	for _, message := range messages {
+		git("commit", "-q", "--allow-empty", "-m", message)
+		commits = append(commits, git("rev-parse", "HEAD"))
+	}
+	return commits
+}
+
+func TestInstallPrePush(t *testing.T) {
+	commits := setupRepo(t, "First", "Second", "Third")
+	if err := exec.Command("git", "update-ref", "refs/remotes/origin/main", commits[0]).Run(); err != nil {
+		t.Fatalf("Failed to create remote branch: %v", err)
+	}
+	if err := exec.Command("git", "checkout", "-q", "--orphan", "unrelated").Run(); err != nil {
+		t.Fatalf("Failed to create orphan branch: %v", err)
+	}
+	if err := exec.Command("git", "commit", "-q", "--allow-empty", "-m", "Root").Run(); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	root, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("Failed to resolve HEAD: %v", err)
+	}
+
+	dir := t.TempDir()
+	logFile := installFakeTool(t, "0")
+
+	chainedLog := filepath.Join(t.TempDir(), "chained.log")
+	if err := os.WriteFile(filepath.Join(dir, PrePush), []byte("#!/bin/sh\ncat > "+chainedLog+"\n"), 0755); err != nil {
+		t.Fatalf("Failed to write hook: %v", err)
+	}
+	if _, err := Install(dir, PrePush); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	zero := strings.Repeat("0", 40)
+	refs := "refs/heads/feature " + commits[2] + " refs/heads/feature " + commits[1] + "\n" +
+		"refs/heads/new " + commits[2] + " refs/heads/new " + zero + "\n" +
+		"refs/heads/rewritten " + commits[2] + " refs/heads/rewritten " + strings.Repeat("1", 40) + "\n" +
+		"refs/heads/pushed " + commits[0] + " refs/heads/pushed " + zero + "\n" +
+		"refs/heads/unrelated " + strings.TrimSpace(string(root)) + " refs/heads/unrelated " + zero + "\n" +
+		"(delete) " + zero + " refs/heads/old " + commits[0] + "\n"
+	if err := runHook(t, filepath.Join(dir, PrePush), refs); err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+
+	// New and rewritten refs are checked from the last commit on a remote, and an
+	// unrelated history from the empty tree
+	emptyTree := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	expected := "--check --range " + commits[1] + ".." + commits[2] + "\n" +
+		"--check --range " + commits[0] + ".." + commits[2] + "\n" +
+		"--check --range " + commits[0] + ".." + commits[2] + "\n" +
+		"--check --range " + emptyTree + ".." + strings.TrimSpace(string(root)) + "\n"
+	if got := readLog(t, logFile); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got := readLog(t, chainedLog); got != refs {
+		t.Errorf("Expected the chained hook to get the refs, got %q", got)
+	}
+}
+
+func TestScriptUnknownHook(t *testing.T) {
+	if _, err := Script("post-merge"); err == nil {
+		t.Errorf("Expected error for unknown hook, got nil")
+	}
+}
@@ -0,0 +1,293 @@
+package notebook
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/horiagug/ruff-format-changes/internal/diff"
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// diffContext is the number of context lines in dry-run diffs of notebooks
+const diffContext = 3
+
+// notebookFormatter formats the changed code cells of notebooks with the wrapped
+// formatter and passes every other file through to it
+type notebookFormatter struct {
+	formatter.Formatter
+	repoRoot string
+	dryRun   bool
+	verbose  bool
+	jobs     int
+	log      io.Writer
+}
+
+// NewFormatter wraps a formatter so that notebooks are formatted cell by cell.
+// Each changed code cell is piped through the formatter's in-memory formatting
+// as Python source, limited to the changed lines of the cell, and only the
+// "source" of modified cells is written back.
+func NewFormatter(inner formatter.Formatter, repoRoot string, dryRun, verbose bool) formatter.Formatter {
+	return &notebookFormatter{
+		Formatter: inner,
+		repoRoot:  repoRoot,
+		dryRun:    dryRun,
+		verbose:   verbose,
+		jobs:      runtime.NumCPU(),
+		log:       os.Stdout,
+	}
+}
+
+// SetJobs sets how many files are formatted in parallel
+func (n *notebookFormatter) SetJobs(jobs int) {
+	n.jobs = jobs
+	n.Formatter.SetJobs(jobs)
+}
+
+// SetLog sets where progress messages are written
+func (n *notebookFormatter) SetLog(log io.Writer) {
+	n.log = log
+	n.Formatter.SetLog(log)
+}
+
+// FormatFilesByLineRanges formats notebooks cell by cell and every other file
+// with the wrapped formatter. Results keep the order of fileChanges.
+func (n *notebookFormatter) FormatFilesByLineRanges(fileChanges []git.FileChanges) (*formatter.Report, error) {
+	var sources, notebooks []git.FileChanges
+	for _, fc := range fileChanges {
+		if IsNotebook(fc.FilePath) {
+			notebooks = append(notebooks, fc)
+		} else {
+			sources = append(sources, fc)
+		}
+	}
+
+	if len(notebooks) == 0 {
+		return n.Formatter.FormatFilesByLineRanges(fileChanges)
+	}
+
+	started := time.Now()
+	report := &formatter.Report{Formatter: n.Name(), DryRun: n.dryRun, Files: []formatter.FileResult{}}
+	if len(sources) > 0 {
+		sourceReport, _ := n.Formatter.FormatFilesByLineRanges(sources)
+		if sourceReport != nil {
+			report.Files = append(report.Files, sourceReport.Files...)
+		}
+	}
+
+	if n.verbose {
+		fmt.Fprintf(n.log, "Found %d notebook(s) with changed lines\n", len(notebooks))
+	}
+	report.Files = append(report.Files, formatter.FormatFiles(notebooks, n.jobs, n.log, n.formatNotebook)...)
+
+	// Report files in the order they were given
+	order := make(map[string]int, len(fileChanges))
+	for i, fc := range fileChanges {
+		order[fc.FilePath] = i
+	}
+	sort.SliceStable(report.Files, func(i, j int) bool {
+		return order[report.Files[i].FilePath] < order[report.Files[j].FilePath]
+	})
+
+	report.Duration = time.Since(started)
+	return report, formatter.FailureError(report)
+}
+
+// FormatContentByLineRanges formats the changed cells of notebook content in
+// memory and passes every other file to the wrapped formatter
+func (n *notebookFormatter) FormatContentByLineRanges(filePath string, content []byte, ranges []git.LineRange) ([]byte, error) {
+	if !IsNotebook(filePath) {
+		return n.Formatter.FormatContentByLineRanges(filePath, content, ranges)
+	}
+
+	formatted, results, err := n.formatCells(filePath, content, ranges, io.Discard)
+	if err != nil {
+		return nil, err
+	}
+	for _, rr := range results {
+		if rr.Status == formatter.StatusFailed {
+			return nil, fmt.Errorf("%s", rr.Error)
+		}
+	}
+	return formatted, nil
+}
+
+// formatNotebook formats the changed cells of a notebook on disk. The notebook
+// is written once, atomically, and left untouched if any cell fails.
+func (n *notebookFormatter) formatNotebook(fc git.FileChanges, log io.Writer) formatter.FileResult {
+	absPath := filepath.Join(n.repoRoot, fc.FilePath)
+	result := formatter.FileResult{FilePath: fc.FilePath}
+	started := time.Now()
+
+	failAll := func(err error) formatter.FileResult {
+		for _, lr := range fc.LineRanges {
+			result.Ranges = append(result.Ranges, formatter.RangeResult{Range: lr, Status: formatter.StatusFailed, Error: err.Error()})
+		}
+		result.Duration = time.Since(started)
+		return result
+	}
+
+	original, err := os.ReadFile(absPath)
+	if err != nil {
+		return failAll(fmt.Errorf("failed to read notebook: %w", err))
+	}
+	result.BeforeHash = formatter.HashContent(original)
+	result.AfterHash = result.BeforeHash
+
+	formatted, results, err := n.formatCells(fc.FilePath, original, fc.LineRanges, log)
+	if err != nil {
+		return failAll(err)
+	}
+	result.Ranges = results
+
+	failed := false
+	for _, rr := range results {
+		if rr.Status == formatter.StatusFailed {
+			failed = true
+		}
+	}
+
+	switch {
+	case failed:
+		// Nothing is written, so cells formatted before the failure stay unchanged on disk
+		for i := range result.Ranges {
+			if result.Ranges[i].Status == formatter.StatusFormatted {
+				result.Ranges[i].Status = formatter.StatusUnchanged
+			}
+		}
+	case n.dryRun:
+		result.Diff = diff.Unified(filepath.ToSlash(fc.FilePath), string(original), string(formatted), diffContext)
+	case !bytes.Equal(formatted, original):
+		if err := formatter.WriteFileAtomic(absPath, formatted); err != nil {
+			return failAll(err)
+		}
+		result.AfterHash = formatter.HashContent(formatted)
+	}
+
+	result.Duration = time.Since(started)
+	return result
+}
+
+// formatCells formats the changed lines of every changed code cell and returns
+// the resulting notebook along with one result per changed cell, whose range
+// spans the changed source lines in the notebook file. Cells containing IPython
+// magics or shell escapes are not valid Python and are skipped.
+func (n *notebookFormatter) formatCells(filePath string, content []byte, ranges []git.LineRange, log io.Writer) ([]byte, []formatter.RangeResult, error) {
+	nb, err := Parse(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse notebook %s: %w", filePath, err)
+	}
+
+	changedStatus := formatter.StatusFormatted
+	if n.dryRun {
+		changedStatus = formatter.StatusWouldReformat
+	}
+
+	// The formatter resolves its configuration from the file name, which must
+	// look like Python source rather than a notebook
+	cellPath := strings.TrimSuffix(filePath, ".ipynb") + ".py"
+
+	changed := nb.ChangedCells(ranges)
+	indexes := make([]int, 0, len(changed))
+	for i := range changed {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var results []formatter.RangeResult
+	for _, i := range indexes {
+		cellRanges := changed[i]
+		rangeResult := formatter.RangeResult{
+			Range: git.LineRange{
+				Start: nb.CellLines(i, cellRanges[0]).Start,
+				End:   nb.CellLines(i, cellRanges[len(cellRanges)-1]).End,
+			},
+		}
+		rangeStarted := time.Now()
+
+		source := nb.Cells[i].Source
+		if hasMagics(source) {
+			if n.verbose {
+				fmt.Fprintf(log, "Skipping cell %d of %s: contains IPython magics\n", i+1, filePath)
+			}
+			rangeResult.Status = formatter.StatusUnchanged
+			results = append(results, rangeResult)
+			continue
+		}
+
+		if n.verbose {
+			fmt.Fprintf(log, "Formatting cell %d of %s\n", i+1, filePath)
+		}
+
+		// Cells don't end with a newline, but formatters always add one
+		trailingNewline := strings.HasSuffix(source, "\n")
+		if !trailingNewline {
+			source += "\n"
+		}
+
+		formatted, err := n.Formatter.FormatContentByLineRanges(cellPath, []byte(source), cellRanges)
+		rangeResult.Duration = time.Since(rangeStarted)
+		if err != nil {
+			rangeResult.Status = formatter.StatusFailed
+			rangeResult.Error = fmt.Sprintf("cell %d: %v", i+1, err)
+			results = append(results, rangeResult)
+			break
+		}
+
+		newSource := string(formatted)
+		if !trailingNewline {
+			newSource = strings.TrimSuffix(newSource, "\n")
+		}
+
+		if newSource != nb.Cells[i].Source {
+			rangeResult.Status = changedStatus
+			nb.SetSource(i, newSource)
+		} else {
+			rangeResult.Status = formatter.StatusUnchanged
+		}
+		results = append(results, rangeResult)
+	}
+
+	return nb.Bytes(), results, nil
+}
+
+// hasMagics reports whether a cell uses IPython syntax, i.e. line or cell magics
+// (%, %%), shell escapes (!) or help (?)
+func hasMagics(source string) bool {
+	for _, line := range strings.Split(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "!") ||
+			strings.HasPrefix(trimmed, "?") || isHelp(trimmed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHelp reports whether a line asks for IPython help with a trailing ? or ??,
+// e.g. "obj.method??" or "np.*load*?". The object must be a dotted name, possibly
+// with wildcards, indexing or calls, so comments and lines of strings that end
+// with a question mark are not mistaken for help.
+func isHelp(line string) bool {
+	name := strings.TrimSuffix(strings.TrimSuffix(line, "?"), "?")
+	if name == line || name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r == '_' || r == '.' || r == '*' || r == '[' || r == ']' || r == '(' || r == ')':
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package notebook
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// stubFormatter adds spaces around "=" on the lines in range and fails on "boom"
+type stubFormatter struct {
+	files []git.FileChanges
+	paths []string
+}
+
+func (s *stubFormatter) Name() string          { return "stub" }
+func (s *stubFormatter) CheckInstalled() error { return nil }
+func (s *stubFormatter) SetJobs(jobs int)      {}
+func (s *stubFormatter) SetLog(log io.Writer)  {}
+
+func (s *stubFormatter) FormatFilesByLineRanges(fileChanges []git.FileChanges) (*formatter.Report, error) {
+	s.files = append(s.files, fileChanges...)
+	report := &formatter.Report{Formatter: "stub"}
+	for _, fc := range fileChanges {
+		report.Files = append(report.Files, formatter.FileResult{FilePath: fc.FilePath})
+	}
+	return report, nil
+}
+
+func (s *stubFormatter) FormatContentByLineRanges(filePath string, content []byte, ranges []git.LineRange) ([]byte, error) {
+	s.paths = append(s.paths, filePath)
+	if strings.Contains(string(content), "boom") {
+		return nil, fmt.Errorf("cannot parse")
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	for _, lr := range ranges {
+		for i := lr.Start - 1; i < lr.End && i < len(lines); i++ {
+			lines[i] = strings.ReplaceAll(lines[i], "=", " = ")
+		}
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+func writeNotebook(t *testing.T, content string) string {
+	t.Helper()
+	repoRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoRoot, "analysis.ipynb"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write notebook: %v", err)
+	}
+	return repoRoot
+}
+
+func TestFormatFilesByLineRangesNotebook(t *testing.T) {
+	repoRoot := writeNotebook(t, testNotebook)
+	stub := &stubFormatter{}
+	fmtr := NewFormatter(stub, repoRoot, false, false)
+
+	// Only line 2 of the first code cell changed; main.py goes to the wrapped formatter
+	report, err := fmtr.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "analysis.ipynb", LineRanges: []git.LineRange{{Start: 25, End: 25}}},
+		{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(stub.files) != 1 || stub.files[0].FilePath != "main.py" {
+		t.Errorf("Expected only main.py to be passed through, got %v", stub.files)
+	}
+	if len(stub.paths) != 1 || stub.paths[0] != "analysis.py" {
+		t.Errorf("Expected the cell to be formatted as analysis.py, got %v", stub.paths)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(repoRoot, "analysis.ipynb"))
+	expected := strings.Replace(testNotebook, `    "b=2\n",`, `    "b = 2\n",`, 1)
+	if string(content) != expected {
+		t.Errorf("Expected only line 2 of the cell to change, got:\n%s", content)
+	}
+
+	if report.Files[0].FilePath != "analysis.ipynb" || report.Files[1].FilePath != "main.py" {
+		t.Errorf("Expected files in the given order, got %s, %s", report.Files[0].FilePath, report.Files[1].FilePath)
+	}
+	ranges := report.Files[0].Ranges
+	if len(ranges) != 1 || ranges[0].Range != (git.LineRange{Start: 25, End: 25}) || ranges[0].Status != formatter.StatusFormatted {
+		t.Errorf("Expected one formatted cell on line 25, got %+v", ranges)
+	}
+}
+
+func TestFormatFilesByLineRangesNotebookDryRun(t *testing.T) {
+	repoRoot := writeNotebook(t, testNotebook)
+	fmtr := NewFormatter(&stubFormatter{}, repoRoot, true, false)
+
+	report, err := fmtr.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "analysis.ipynb", LineRanges: []git.LineRange{{Start: 1, End: 50}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(repoRoot, "analysis.ipynb"))
+	if string(content) != testNotebook {
+		t.Errorf("Expected notebook to be untouched in dry-run mode")
+	}
+
+	file := report.Files[0]
+	if file.Status() != formatter.StatusWouldReformat || len(file.Ranges) != 2 {
+		t.Errorf("Expected two cells to be reformatted, got %+v", file.Ranges)
+	}
+	if !strings.Contains(file.Diff, `+    "a = 1\n",`) || !strings.Contains(file.Diff, `+   "source": "c = 3\nd = 4"`) {
+		t.Errorf("Unexpected diff:\n%s", file.Diff)
+	}
+}
+
+func TestFormatFilesByLineRangesNotebookSkipsMagicsAndFailures(t *testing.T) {
+	notebook := `{"cells": [
+ {"cell_type": "code", "source": ["%matplotlib inline\n", "a=1"]},
+ {"cell_type": "code", "source": ["b=2"]},
+ {"cell_type": "code", "source": ["boom=3"]}
+]}`
+	repoRoot := writeNotebook(t, notebook)
+	stub := &stubFormatter{}
+	fmtr := NewFormatter(stub, repoRoot, false, false)
+
+	report, err := fmtr.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "analysis.ipynb", LineRanges: []git.LineRange{{Start: 1, End: 5}}},
+	})
+	if err == nil {
+		t.Fatalf("Expected error from failing cell, got nil")
+	}
+
+	// The cell with magics is never passed to the formatter and nothing is written
+	if len(stub.paths) != 2 {
+		t.Errorf("Expected 2 cells to be formatted, got %d", len(stub.paths))
+	}
+	content, _ := os.ReadFile(filepath.Join(repoRoot, "analysis.ipynb"))
+	if string(content) != notebook {
+		t.Errorf("Expected notebook to be untouched after a failure, got:\n%s", content)
+	}
+
+	ranges := report.Files[0].Ranges
+	if len(ranges) != 3 || ranges[0].Status != formatter.StatusUnchanged || ranges[1].Status != formatter.StatusUnchanged || ranges[2].Status != formatter.StatusFailed {
+		t.Errorf("Expected magics skipped, cell 2 not written and cell 3 failed, got %+v", ranges)
+	}
+}
+
+func TestFormatContentByLineRangesNotebook(t *testing.T) {
+	fmtr := NewFormatter(&stubFormatter{}, t.TempDir(), false, false)
+
+	formatted, err := fmtr.FormatContentByLineRanges("nb.ipynb", []byte(testNotebook), []git.LineRange{{Start: 24, End: 24}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := strings.Replace(testNotebook, `    "a=1\n",
+    "b=2\n",`, `    "a = 1\n",
+    "b=2\n",`, 1)
+	if string(formatted) != expected {
+		t.Errorf("Expected only line 1 of the cell to change, got:\n%s", formatted)
+	}
+}
+
+func TestHasMagics(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected bool
+	}{
+		{source: "%matplotlib inline\na = 1", expected: true},
+		{source: "!pip install ruff", expected: true},
+		{source: "len?", expected: true},
+		{source: "np.load??", expected: true},
+		{source: "np.*load*?", expected: true},
+		{source: "?print", expected: true},
+		{source: "a = 1  # why?", expected: false},
+		{source: "# is this right?", expected: false},
+		{source: "doc = \"\"\"\nIs it?\nwhat is 'it'?\n\"\"\"", expected: false},
+		{source: "a = 1", expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := hasMagics(tt.source); got != tt.expected {
+			t.Errorf("hasMagics(%q) = %v, want %v", tt.source, got, tt.expected)
+		}
+	}
+}
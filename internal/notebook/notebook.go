@@ -0,0 +1,350 @@
+package notebook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// IsNotebook reports whether path is a Jupyter notebook
+func IsNotebook(path string) bool {
+	return strings.HasSuffix(path, ".ipynb")
+}
+
+// Cell is a notebook cell along with the location of its source in the notebook file
+type Cell struct {
+	Type   string
+	Source string
+
+	// sourceStart and sourceEnd are the byte offsets of the "source" value
+	sourceStart int
+	sourceEnd   int
+	// sourceLines holds the file line of each element of a source array. It is
+	// nil when the source is stored as a single string.
+	sourceLines []int
+	changed     bool
+}
+
+// Notebook is a parsed notebook that can be written back with only the sources
+// of modified cells replaced, leaving outputs, metadata and layout untouched
+type Notebook struct {
+	data       []byte
+	lineStarts []int
+	Cells      []Cell
+}
+
+// Parse reads the cells of a notebook and records where each cell's source is
+// stored in data
+func Parse(data []byte) (*Notebook, error) {
+	nb := &Notebook{data: data, lineStarts: []int{0}}
+	for i, b := range data {
+		if b == '\n' {
+			nb.lineStarts = append(nb.lineStarts, i+1)
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		key, err := readKey(dec)
+		if err != nil {
+			return nil, err
+		}
+		if key != "cells" {
+			if err := skipValue(dec); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return nil, err
+		}
+		for dec.More() {
+			cell, err := nb.parseCell(dec)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cell %d: %w", len(nb.Cells), err)
+			}
+			nb.Cells = append(nb.Cells, cell)
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return nil, err
+		}
+	}
+
+	return nb, nil
+}
+
+// parseCell reads a cell object, recording the byte span of its source and the
+// file line of every source line
+func (nb *Notebook) parseCell(dec *json.Decoder) (Cell, error) {
+	var cell Cell
+	if err := expectDelim(dec, '{'); err != nil {
+		return cell, err
+	}
+
+	for dec.More() {
+		key, err := readKey(dec)
+		if err != nil {
+			return cell, err
+		}
+
+		switch key {
+		case "cell_type":
+			if err := dec.Decode(&cell.Type); err != nil {
+				return cell, err
+			}
+		case "source":
+			cell.sourceStart = valueStart(nb.data, int(dec.InputOffset()))
+			if err := nb.parseSource(dec, &cell); err != nil {
+				return cell, err
+			}
+			cell.sourceEnd = int(dec.InputOffset())
+		default:
+			if err := skipValue(dec); err != nil {
+				return cell, err
+			}
+		}
+	}
+
+	return cell, expectDelim(dec, '}')
+}
+
+// parseSource reads a source value, which nbformat stores either as an array
+// of lines or as a single string
+func (nb *Notebook) parseSource(dec *json.Decoder, cell *Cell) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if s, ok := tok.(string); ok {
+		cell.Source = s
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("unexpected source %v", tok)
+	}
+
+	var source strings.Builder
+	cell.sourceLines = []int{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		line, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("unexpected source line %v", tok)
+		}
+		source.WriteString(line)
+		// A JSON string can't span lines, so its end is on the line it starts on
+		cell.sourceLines = append(cell.sourceLines, nb.lineAt(int(dec.InputOffset())-1))
+	}
+	cell.Source = source.String()
+
+	return expectDelim(dec, ']')
+}
+
+// lineAt returns the one-based line of the byte at offset
+func (nb *Notebook) lineAt(offset int) int {
+	return sort.Search(len(nb.lineStarts), func(i int) bool { return nb.lineStarts[i] > offset })
+}
+
+// ChangedCells maps line ranges of the notebook file to the code cells whose
+// source they touch. Ranges of the result are one-based lines within each
+// cell's source. A cell stored as a single string is changed as a whole.
+func (nb *Notebook) ChangedCells(ranges []git.LineRange) map[int][]git.LineRange {
+	inRanges := func(line int) bool {
+		for _, lr := range ranges {
+			if line >= lr.Start && line <= lr.End {
+				return true
+			}
+		}
+		return false
+	}
+
+	changed := make(map[int][]git.LineRange)
+	for i, cell := range nb.Cells {
+		if cell.Type != "code" || cell.Source == "" {
+			continue
+		}
+
+		if cell.sourceLines == nil {
+			if inRanges(nb.lineAt(cell.sourceStart)) {
+				changed[i] = []git.LineRange{{Start: 1, End: strings.Count(strings.TrimSuffix(cell.Source, "\n"), "\n") + 1}}
+			}
+			continue
+		}
+
+		var cellRanges []git.LineRange
+		for j, line := range cell.sourceLines {
+			if !inRanges(line) {
+				continue
+			}
+			if n := len(cellRanges); n > 0 && cellRanges[n-1].End == j {
+				cellRanges[n-1].End = j + 1
+			} else {
+				cellRanges = append(cellRanges, git.LineRange{Start: j + 1, End: j + 1})
+			}
+		}
+		if len(cellRanges) > 0 {
+			changed[i] = cellRanges
+		}
+	}
+
+	return changed
+}
+
+// CellLines returns the file lines holding the given one-based source lines of a cell
+func (nb *Notebook) CellLines(index int, lr git.LineRange) git.LineRange {
+	cell := nb.Cells[index]
+	if cell.sourceLines == nil {
+		line := nb.lineAt(cell.sourceStart)
+		return git.LineRange{Start: line, End: line}
+	}
+	return git.LineRange{Start: cell.sourceLines[lr.Start-1], End: cell.sourceLines[lr.End-1]}
+}
+
+// SetSource replaces the source of a cell
+func (nb *Notebook) SetSource(index int, source string) {
+	if nb.Cells[index].Source == source {
+		return
+	}
+	nb.Cells[index].Source = source
+	nb.Cells[index].changed = true
+}
+
+// Bytes returns the notebook with the sources of modified cells re-encoded in
+// place. Everything else is copied from the original file byte for byte.
+func (nb *Notebook) Bytes() []byte {
+	var out bytes.Buffer
+	next := 0
+	for _, cell := range nb.Cells {
+		if !cell.changed {
+			continue
+		}
+		out.Write(nb.data[next:cell.sourceStart])
+		out.WriteString(nb.encodeSource(cell))
+		next = cell.sourceEnd
+	}
+	out.Write(nb.data[next:])
+	return out.Bytes()
+}
+
+// encodeSource encodes a cell's source the way the original file stored it: as
+// a single string, or as an array of lines laid out with the original indentation
+func (nb *Notebook) encodeSource(cell Cell) string {
+	if cell.sourceLines == nil {
+		return encodeString(cell.Source)
+	}
+
+	lines := splitSource(cell.Source)
+	original := nb.data[cell.sourceStart:cell.sourceEnd]
+	if !bytes.Contains(original, []byte("\n")) {
+		var encoded []string
+		for _, line := range lines {
+			encoded = append(encoded, encodeString(line))
+		}
+		return "[" + strings.Join(encoded, ", ") + "]"
+	}
+
+	// Indent elements like the first original element and "]" like the original "]"
+	elementIndent := nb.indentOf(cell.sourceStart) + " "
+	if len(cell.sourceLines) > 0 {
+		elementIndent = nb.indentOf(nb.lineStarts[cell.sourceLines[0]-1])
+	}
+	closeIndent := nb.indentOf(nb.lineStarts[nb.lineAt(cell.sourceEnd-1)-1])
+
+	var sb strings.Builder
+	sb.WriteString("[\n")
+	for i, line := range lines {
+		sb.WriteString(elementIndent)
+		sb.WriteString(encodeString(line))
+		if i < len(lines)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(closeIndent)
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// indentOf returns the leading whitespace of the line containing offset
+func (nb *Notebook) indentOf(offset int) string {
+	start := nb.lineStarts[nb.lineAt(offset)-1]
+	end := start
+	for end < len(nb.data) && (nb.data[end] == ' ' || nb.data[end] == '\t') {
+		end++
+	}
+	return string(nb.data[start:end])
+}
+
+// splitSource splits a source into lines, keeping the line endings
+func splitSource(source string) []string {
+	lines := strings.SplitAfter(source, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// encodeString encodes s as a JSON string without escaping HTML characters,
+// matching how Jupyter writes notebooks
+func encodeString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// valueStart returns the offset of the value following a key that ends at offset
+func valueStart(data []byte, offset int) int {
+	for offset < len(data) && (data[offset] == ':' || data[offset] == ' ' || data[offset] == '\t' || data[offset] == '\n' || data[offset] == '\r') {
+		offset++
+	}
+	return offset
+}
+
+// readKey reads an object key
+func readKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("expected object key, got %v", tok)
+	}
+	return key, nil
+}
+
+// expectDelim reads a delimiter token and fails if it is not delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return fmt.Errorf("unexpected end of notebook, expected %v", delim)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid notebook: %w", err)
+	}
+	if tok != delim {
+		return fmt.Errorf("invalid notebook: expected %v, got %v", delim, tok)
+	}
+	return nil
+}
+
+// skipValue reads and discards the next value
+func skipValue(dec *json.Decoder) error {
+	var raw json.RawMessage
+	return dec.Decode(&raw)
+}
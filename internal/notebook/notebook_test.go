@@ -0,0 +1,173 @@
+package notebook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// testNotebook is laid out the way Jupyter writes notebooks, one source line per file line
+const testNotebook = `{
+ "cells": [
+  {
+   "cell_type": "markdown",
+   "metadata": {},
+   "source": [
+    "# Title <b>&</b>"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": 1,
+   "metadata": {"tags": ["x"]},
+   "outputs": [
+    {
+     "name": "stdout",
+     "output_type": "stream",
+     "text": [
+      "a=1\n"
+     ]
+    }
+   ],
+   "source": [
+    "a=1\n",
+    "b=2\n",
+    "print(a)"
+   ]
+  },
+  {
+   "cell_type": "code",
+   "execution_count": null,
+   "metadata": {},
+   "outputs": [],
+   "source": "c=3\nd=4"
+  }
+ ],
+ "metadata": {
+  "kernelspec": {"name": "python3"}
+ },
+ "nbformat": 4,
+ "nbformat_minor": 5
+}
+`
+
+func TestParse(t *testing.T) {
+	nb, err := Parse([]byte(testNotebook))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(nb.Cells) != 3 {
+		t.Fatalf("Expected 3 cells, got %d", len(nb.Cells))
+	}
+	if nb.Cells[0].Type != "markdown" || nb.Cells[1].Type != "code" {
+		t.Errorf("Unexpected cell types %q, %q", nb.Cells[0].Type, nb.Cells[1].Type)
+	}
+	if nb.Cells[1].Source != "a=1\nb=2\nprint(a)" {
+		t.Errorf("Unexpected source %q", nb.Cells[1].Source)
+	}
+	if !reflect.DeepEqual(nb.Cells[1].sourceLines, []int{24, 25, 26}) {
+		t.Errorf("Expected source on lines 24-26, got %v", nb.Cells[1].sourceLines)
+	}
+	if nb.Cells[2].Source != "c=3\nd=4" {
+		t.Errorf("Unexpected string source %q", nb.Cells[2].Source)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, data := range []string{"", "[]", `{"cells": [{"source": 1}]}`, `{"cells": [`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected error for %q, got nil", data)
+		}
+	}
+}
+
+func TestChangedCells(t *testing.T) {
+	nb, err := Parse([]byte(testNotebook))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Line 7 is markdown, line 19 is an output, lines 25-26 are code and 34 is the string source
+	changed := nb.ChangedCells([]git.LineRange{{Start: 7, End: 7}, {Start: 19, End: 19}, {Start: 25, End: 26}, {Start: 34, End: 34}})
+
+	expected := map[int][]git.LineRange{
+		1: {{Start: 2, End: 3}},
+		2: {{Start: 1, End: 2}},
+	}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v, got %v", expected, changed)
+	}
+
+	if lines := nb.CellLines(1, git.LineRange{Start: 2, End: 3}); lines != (git.LineRange{Start: 25, End: 26}) {
+		t.Errorf("Expected file lines 25-26, got %v", lines)
+	}
+}
+
+func TestBytesUnchanged(t *testing.T) {
+	nb, err := Parse([]byte(testNotebook))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	nb.SetSource(1, nb.Cells[1].Source)
+
+	if string(nb.Bytes()) != testNotebook {
+		t.Errorf("Expected notebook to round trip unchanged, got:\n%s", nb.Bytes())
+	}
+}
+
+func TestBytesReplacesOnlySource(t *testing.T) {
+	nb, err := Parse([]byte(testNotebook))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	nb.SetSource(0, "# Title <b>&</b>\n\nMore")
+	nb.SetSource(1, "a = 1\nb = 2\nprint(a)")
+	nb.SetSource(2, "c = 3\nd=4")
+
+	expected := testNotebook
+	expected = replaceOnce(t, expected, `    "# Title <b>&</b>"`, `    "# Title <b>&</b>\n",
+    "\n",
+    "More"`)
+	expected = replaceOnce(t, expected, `    "a=1\n",
+    "b=2\n",`, `    "a = 1\n",
+    "b = 2\n",`)
+	expected = replaceOnce(t, expected, `"c=3\nd=4"`, `"c = 3\nd=4"`)
+
+	if string(nb.Bytes()) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, nb.Bytes())
+	}
+
+	// The result is still a valid notebook with the new sources
+	reparsed, err := Parse(nb.Bytes())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if reparsed.Cells[1].Source != "a = 1\nb = 2\nprint(a)" {
+		t.Errorf("Unexpected source after round trip %q", reparsed.Cells[1].Source)
+	}
+}
+
+func TestBytesInlineSource(t *testing.T) {
+	nb, err := Parse([]byte(`{"cells": [{"cell_type": "code", "source": ["x=1\n", "y"]}]}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	nb.SetSource(0, "x = 1\ny")
+
+	expected := `{"cells": [{"cell_type": "code", "source": ["x = 1\n", "y"]}]}`
+	if string(nb.Bytes()) != expected {
+		t.Errorf("Expected %s, got %s", expected, nb.Bytes())
+	}
+}
+
+// replaceOnce replaces the single occurrence of old in s
+func replaceOnce(t *testing.T, s, old, new string) string {
+	t.Helper()
+	if strings.Count(s, old) != 1 {
+		t.Fatalf("Expected exactly one %q", old)
+	}
+	return strings.Replace(s, old, new, 1)
+}
@@ -0,0 +1,50 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+)
+
+// WriteGitHub renders the ranges that need formatting or failed as GitHub Actions
+// workflow commands, which show up as annotations on pull requests
+func WriteGitHub(w io.Writer, report *formatter.Report) {
+	for _, f := range report.Files {
+		for _, rr := range f.Ranges {
+			var level string
+			switch rr.Status {
+			case formatter.StatusWouldReformat:
+				level = "warning"
+			case formatter.StatusFailed:
+				level = "error"
+			default:
+				continue
+			}
+
+			message := findingMessage(rr.Status)
+			if rr.Error != "" {
+				message += ": " + rr.Error
+			}
+
+			fmt.Fprintf(w, "::%s file=%s,line=%d,endLine=%d,title=%s::%s\n",
+				level, escapeProperty(f.FilePath), rr.Range.Start, rr.Range.End,
+				escapeProperty(toolName), escapeData(message))
+		}
+	}
+}
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
@@ -0,0 +1,111 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+)
+
+// jsonReport is the JSON representation of a report
+type jsonReport struct {
+	Formatter  string     `json:"formatter"`
+	DryRun     bool       `json:"dry_run"`
+	DurationMs float64    `json:"duration_ms"`
+	Summary    jsonCounts `json:"summary"`
+	Files      []jsonFile `json:"files"`
+}
+
+// jsonCounts holds the number of ranges per status
+type jsonCounts struct {
+	Formatted     int `json:"formatted"`
+	Unchanged     int `json:"unchanged"`
+	WouldReformat int `json:"would_reformat"`
+	Failed        int `json:"failed"`
+}
+
+// jsonFile is the JSON representation of a file result
+type jsonFile struct {
+	Path       string      `json:"path"`
+	Status     string      `json:"status"`
+	BeforeHash string      `json:"before_hash"`
+	AfterHash  string      `json:"after_hash"`
+	DurationMs float64     `json:"duration_ms"`
+	Diff       string      `json:"diff,omitempty"`
+	RolledBack bool        `json:"rolled_back,omitempty"`
+	Ranges     []jsonRange `json:"ranges"`
+	// OutOfRange lists edits outside the changed lines found by --strict-ranges
+	OutOfRange         []jsonLines `json:"out_of_range,omitempty"`
+	OutOfRangeReverted bool        `json:"out_of_range_reverted,omitempty"`
+}
+
+// jsonLines is a span of original lines
+type jsonLines struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// jsonRange is the JSON representation of a range result
+type jsonRange struct {
+	Start      int     `json:"start"`
+	End        int     `json:"end"`
+	Status     string  `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	Stderr     string  `json:"stderr,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// WriteJSON renders a report as an indented JSON document
+func WriteJSON(w io.Writer, report *formatter.Report) error {
+	doc := jsonReport{
+		Formatter:  report.Formatter,
+		DryRun:     report.DryRun,
+		DurationMs: milliseconds(report.Duration),
+		Summary: jsonCounts{
+			Formatted:     report.CountRanges(formatter.StatusFormatted),
+			Unchanged:     report.CountRanges(formatter.StatusUnchanged),
+			WouldReformat: report.CountRanges(formatter.StatusWouldReformat),
+			Failed:        report.CountRanges(formatter.StatusFailed),
+		},
+		Files: []jsonFile{},
+	}
+
+	for _, f := range report.Files {
+		file := jsonFile{
+			Path:       f.FilePath,
+			Status:     string(f.Status()),
+			BeforeHash: f.BeforeHash,
+			AfterHash:  f.AfterHash,
+			DurationMs: milliseconds(f.Duration),
+			Diff:       f.Diff,
+			RolledBack: f.RolledBack,
+			Ranges:     []jsonRange{},
+
+			OutOfRangeReverted: f.OutOfRangeReverted,
+		}
+		for _, lr := range f.OutOfRange {
+			file.OutOfRange = append(file.OutOfRange, jsonLines{Start: lr.Start, End: lr.End})
+		}
+		for _, rr := range f.Ranges {
+			file.Ranges = append(file.Ranges, jsonRange{
+				Start:      rr.Range.Start,
+				End:        rr.Range.End,
+				Status:     string(rr.Status),
+				DurationMs: milliseconds(rr.Duration),
+				Stderr:     rr.Stderr,
+				Error:      rr.Error,
+			})
+		}
+		doc.Files = append(doc.Files, file)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
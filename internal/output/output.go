@@ -0,0 +1,48 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+)
+
+// Formats lists the output formats accepted by Write
+var Formats = []string{"text", "json", "sarif", "github"}
+
+// Write renders a report in the given output format
+func Write(w io.Writer, format string, report *formatter.Report, verbose bool) error {
+	switch format {
+	case "", "text":
+		WriteText(w, report, verbose)
+		return nil
+	case "json":
+		return WriteJSON(w, report)
+	case "sarif":
+		return WriteSARIF(w, report)
+	case "github":
+		WriteGitHub(w, report)
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q (available: %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// ValidateFormat returns an error if format is not a known output format
+func ValidateFormat(format string) error {
+	for _, f := range Formats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q (available: %s)", format, strings.Join(Formats, ", "))
+}
+
+// findingMessage returns the message reported for a range needing attention
+func findingMessage(status formatter.Status) string {
+	if status == formatter.StatusFailed {
+		return "Formatter failed on changed lines"
+	}
+	return "Changed lines are not formatted"
+}
@@ -0,0 +1,132 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// sampleReport returns a dry-run report with one range of each interesting status
+func sampleReport() *formatter.Report {
+	return &formatter.Report{
+		Formatter: "ruff",
+		DryRun:    true,
+		Duration:  1500 * time.Microsecond,
+		Files: []formatter.FileResult{
+			{
+				FilePath:   "pkg/main.py",
+				BeforeHash: "abc",
+				AfterHash:  "abc",
+				Diff:       "-x=1\n+x = 1\n",
+				Ranges: []formatter.RangeResult{
+					{Range: git.LineRange{Start: 2, End: 4}, Status: formatter.StatusWouldReformat},
+					{Range: git.LineRange{Start: 9, End: 9}, Status: formatter.StatusUnchanged},
+				},
+			},
+			{
+				FilePath: "broken,file.py",
+				Ranges: []formatter.RangeResult{
+					{Range: git.LineRange{Start: 1, End: 1}, Status: formatter.StatusFailed, Error: "ruff format failed: exit status 2"},
+				},
+			},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "json", sampleReport(), false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var doc jsonReport
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	if doc.Formatter != "ruff" || !doc.DryRun || doc.DurationMs != 1.5 {
+		t.Errorf("Unexpected report header: %+v", doc)
+	}
+	if doc.Summary != (jsonCounts{Unchanged: 1, WouldReformat: 1, Failed: 1}) {
+		t.Errorf("Unexpected summary: %+v", doc.Summary)
+	}
+	if len(doc.Files) != 2 || doc.Files[0].Status != "would-reformat" || len(doc.Files[0].Ranges) != 2 {
+		t.Fatalf("Unexpected files: %+v", doc.Files)
+	}
+	if doc.Files[0].Ranges[0].Start != 2 || doc.Files[0].Ranges[0].End != 4 {
+		t.Errorf("Unexpected first range: %+v", doc.Files[0].Ranges[0])
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "sarif", sampleReport(), false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, buf.String())
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Unexpected SARIF log: %+v", log)
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results (unchanged ranges are omitted), got %d", len(results))
+	}
+
+	first := results[0]
+	region := first.Locations[0].PhysicalLocation.Region
+	if first.RuleID != ruleFormat || first.Level != "warning" || region.StartLine != 2 || region.EndLine != 4 {
+		t.Errorf("Unexpected first result: %+v", first)
+	}
+	if first.Locations[0].PhysicalLocation.ArtifactLocation.URI != "pkg/main.py" {
+		t.Errorf("Unexpected artifact location: %+v", first.Locations[0])
+	}
+	if results[1].RuleID != ruleFailed || results[1].Level != "error" {
+		t.Errorf("Unexpected second result: %+v", results[1])
+	}
+}
+
+func TestWriteGitHub(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "github", sampleReport(), false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"::warning file=pkg/main.py,line=2,endLine=4,title=ruff-format-changes::Changed lines are not formatted",
+		"::error file=broken%2Cfile.py,line=1,endLine=1,title=ruff-format-changes::Formatter failed on changed lines: ruff format failed: exit status 2",
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d annotations, got %d:\n%s", len(expected), len(lines), buf.String())
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Errorf("Annotation %d:\nexpected %s\ngot      %s", i, expected[i], lines[i])
+		}
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "xml", sampleReport(), false); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+	if err := ValidateFormat("xml"); err == nil {
+		t.Errorf("Expected ValidateFormat to reject unknown format")
+	}
+	if err := ValidateFormat("sarif"); err != nil {
+		t.Errorf("Expected ValidateFormat to accept sarif, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolName     = "ruff-format-changes"
+	toolURI      = "https://github.com/horiagug/ruff-format-changes"
+	ruleFormat   = "unformatted-changed-lines"
+	ruleFailed   = "formatter-failed"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine"`
+}
+
+// WriteSARIF renders the ranges that need formatting or failed as a SARIF 2.1.0
+// log, suitable for uploading to code scanning
+func WriteSARIF(w io.Writer, report *formatter.Report) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           toolName,
+			InformationURI: toolURI,
+			Rules: []sarifRule{
+				{ID: ruleFormat, ShortDescription: sarifMessage{Text: findingMessage(formatter.StatusWouldReformat)}},
+				{ID: ruleFailed, ShortDescription: sarifMessage{Text: findingMessage(formatter.StatusFailed)}},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, f := range report.Files {
+		for _, rr := range f.Ranges {
+			ruleID, level := ruleFormat, "warning"
+			switch rr.Status {
+			case formatter.StatusWouldReformat:
+			case formatter.StatusFailed:
+				ruleID, level = ruleFailed, "error"
+			default:
+				continue
+			}
+
+			message := findingMessage(rr.Status)
+			if rr.Error != "" {
+				message += ": " + rr.Error
+			}
+
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  ruleID,
+				Level:   level,
+				Message: sarifMessage{Text: message},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: f.FilePath},
+					Region:           sarifRegion{StartLine: rr.Range.Start, EndLine: rr.Range.End},
+				}}},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// WriteText renders a report as human readable text. In dry-run mode the
+// formatter's diffs are printed; verbose adds unchanged ranges and timings.
+func WriteText(w io.Writer, report *formatter.Report, verbose bool) {
+	for _, f := range report.Files {
+		if report.DryRun && f.Diff != "" {
+			fmt.Fprintln(w, strings.TrimRight(f.Diff, "\n"))
+			fmt.Fprintln(w)
+		}
+
+		for _, rr := range f.Ranges {
+			if rr.Status == formatter.StatusUnchanged && !verbose {
+				continue
+			}
+
+			fmt.Fprintf(w, "%s %s (%s)", statusLabel(rr.Status), f.FilePath, DescribeRange(rr.Range))
+			if verbose {
+				fmt.Fprintf(w, " in %s", rr.Duration.Round(time.Microsecond))
+			}
+			fmt.Fprintln(w)
+
+			if rr.Status == formatter.StatusFailed {
+				if rr.Error != "" {
+					fmt.Fprintf(w, "  %s\n", rr.Error)
+				}
+				if stderr := strings.TrimSpace(rr.Stderr); stderr != "" && !strings.Contains(rr.Error, stderr) {
+					fmt.Fprintf(w, "  %s\n", strings.ReplaceAll(stderr, "\n", "\n  "))
+				}
+			}
+		}
+
+		if f.RolledBack {
+			fmt.Fprintf(w, "Rolled back %s\n", f.FilePath)
+		}
+
+		for _, lr := range f.OutOfRange {
+			label := "Edit outside changed lines in"
+			if f.OutOfRangeReverted {
+				label = "Reverted edit outside changed lines in"
+			}
+			fmt.Fprintf(w, "%s %s (%s)\n", label, f.FilePath, DescribeRange(lr))
+		}
+
+		if verbose && f.BeforeHash != f.AfterHash {
+			fmt.Fprintf(w, "  %s: %.12s -> %.12s\n", f.FilePath, f.BeforeHash, f.AfterHash)
+		}
+	}
+
+	fmt.Fprintln(w, summary(report))
+}
+
+// summary returns a one line summary of the report
+func summary(report *formatter.Report) string {
+	var parts []string
+
+	if report.DryRun {
+		if n := report.CountRanges(formatter.StatusWouldReformat); n > 0 {
+			parts = append(parts, fmt.Sprintf("%d range(s) in %d file(s) would be reformatted",
+				n, report.CountFiles(formatter.StatusWouldReformat)))
+		}
+	} else if n := report.CountRanges(formatter.StatusFormatted); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d range(s) in %d file(s) reformatted",
+			n, report.CountFiles(formatter.StatusFormatted)))
+	}
+
+	if n := report.CountRanges(formatter.StatusUnchanged); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d range(s) already formatted", n))
+	}
+
+	if n := report.CountRanges(formatter.StatusFailed); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d range(s) failed", n))
+	}
+
+	if n := report.CountOutOfRange(); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d edit(s) outside changed lines", n))
+	}
+
+	if n := report.CountRolledBack(); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d file(s) rolled back", n))
+	}
+
+	if len(parts) == 0 {
+		return "No changed lines to format"
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// statusLabel returns the text shown in front of a range with the given status
+func statusLabel(status formatter.Status) string {
+	switch status {
+	case formatter.StatusFormatted:
+		return "Formatted"
+	case formatter.StatusWouldReformat:
+		return "Would reformat"
+	case formatter.StatusFailed:
+		return "Failed"
+	default:
+		return "Unchanged"
+	}
+}
+
+// DescribeRange returns "line N" or "lines N-M"
+func DescribeRange(lr git.LineRange) string {
+	if lr.Start == lr.End {
+		return fmt.Sprintf("line %d", lr.Start)
+	}
+	return fmt.Sprintf("lines %d-%d", lr.Start, lr.End)
+}
@@ -0,0 +1,80 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+func TestWriteTextDryRun(t *testing.T) {
+	report := &formatter.Report{
+		DryRun: true,
+		Files: []formatter.FileResult{
+			{
+				FilePath: "main.py",
+				Diff:     "--- main.py\n+++ main.py\n",
+				Ranges: []formatter.RangeResult{
+					{Range: git.LineRange{Start: 2, End: 2}, Status: formatter.StatusWouldReformat},
+					{Range: git.LineRange{Start: 5, End: 7}, Status: formatter.StatusUnchanged},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	WriteText(&buf, report, false)
+	out := buf.String()
+
+	for _, expected := range []string{
+		"--- main.py\n+++ main.py\n",
+		"Would reformat main.py (line 2)\n",
+		"1 range(s) in 1 file(s) would be reformatted, 1 range(s) already formatted\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "lines 5-7") {
+		t.Errorf("Expected unchanged ranges to be hidden without verbose, got:\n%s", out)
+	}
+}
+
+func TestWriteTextFailure(t *testing.T) {
+	report := &formatter.Report{
+		Files: []formatter.FileResult{
+			{
+				FilePath: "main.py",
+				Ranges: []formatter.RangeResult{
+					{Range: git.LineRange{Start: 1, End: 3}, Status: formatter.StatusFailed, Error: "ruff format failed: exit status 2", Stderr: "error: Failed to parse main.py"},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	WriteText(&buf, report, false)
+	out := buf.String()
+
+	for _, expected := range []string{
+		"Failed main.py (lines 1-3)\n",
+		"  ruff format failed: exit status 2\n",
+		"  error: Failed to parse main.py\n",
+		"1 range(s) failed\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestWriteTextEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	WriteText(&buf, &formatter.Report{}, false)
+
+	if buf.String() != "No changed lines to format\n" {
+		t.Errorf("Unexpected output for empty report: %q", buf.String())
+	}
+}
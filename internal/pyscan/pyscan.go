@@ -0,0 +1,245 @@
+// Package pyscan finds the logical lines of Python source without parsing it,
+// by tracking brackets, strings, comments and line continuations. It tolerates
+// invalid source, which is common in the middle of an edit.
+package pyscan
+
+import "strings"
+
+// tabSize is the column width of a tab in indentation, as in Python's tokenizer
+const tabSize = 8
+
+// Statement is a logical line of source: a simple statement or the header of a
+// compound statement, possibly spanning several physical lines through brackets,
+// triple-quoted strings or backslash continuations
+type Statement struct {
+	// Start and End are the one-based physical lines of the statement
+	Start int
+	End   int
+	// Indent is the indentation width of the first line
+	Indent int
+	// Keyword is the first word of the statement, e.g. "def" (also for async
+	// def), or "@" for a decorator
+	Keyword string
+}
+
+// File is scanned Python source
+type File struct {
+	Statements []Statement
+	// statementAt maps each zero-based physical line to the index of its
+	// statement, or -1 for blank and comment-only lines
+	statementAt []int
+}
+
+// Scan finds the statements of src
+func Scan(src []byte) *File {
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	f := &File{statementAt: make([]int, len(lines))}
+	for i := range f.statementAt {
+		f.statementAt[i] = -1
+	}
+
+	var s scanner
+	current := -1
+	for i, line := range lines {
+		if current < 0 {
+			trimmed := strings.TrimLeft(line, " \t\f")
+			if trimmed == "" || trimmed[0] == '\n' || trimmed[0] == '\r' || trimmed[0] == '#' {
+				continue
+			}
+			f.Statements = append(f.Statements, Statement{
+				Start:   i + 1,
+				Indent:  indentWidth(line),
+				Keyword: keyword(trimmed),
+			})
+			current = len(f.Statements) - 1
+		}
+
+		f.statementAt[i] = current
+		f.Statements[current].End = i + 1
+		if !s.continues(line) {
+			current = -1
+		}
+	}
+
+	return f
+}
+
+// LineCount returns the number of physical lines
+func (f *File) LineCount() int {
+	return len(f.statementAt)
+}
+
+// StatementAt returns the statement containing the one-based physical line, or
+// false if the line is blank, a comment or out of range
+func (f *File) StatementAt(line int) (Statement, bool) {
+	if line < 1 || line > len(f.statementAt) || f.statementAt[line-1] < 0 {
+		return Statement{}, false
+	}
+	return f.Statements[f.statementAt[line-1]], true
+}
+
+// HasCode reports whether any of the one-based physical lines from start to end
+// belongs to a statement, rather than being blank or a comment
+func (f *File) HasCode(start, end int) bool {
+	for line := max(start, 1); line <= min(end, len(f.statementAt)); line++ {
+		if f.statementAt[line-1] >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Span returns the lines of the statement containing the one-based physical
+// line. A decorated definition and its decorators are one span, from the first
+// decorator to the end of the definition's header. It returns false if the line
+// is blank, a comment or out of range.
+func (f *File) Span(line int) (start, end int, ok bool) {
+	if line < 1 || line > len(f.statementAt) || f.statementAt[line-1] < 0 {
+		return 0, 0, false
+	}
+	i := f.statementAt[line-1]
+
+	// Move from a decorator to the definition it decorates
+	d := i
+	for d+1 < len(f.Statements) && f.Statements[d].Keyword == "@" && f.Statements[d+1].Indent == f.Statements[i].Indent {
+		d++
+	}
+	if d == i && f.Statements[i].Keyword != "def" && f.Statements[i].Keyword != "class" {
+		return f.Statements[i].Start, f.Statements[i].End, true
+	}
+	return f.Statements[f.firstDecorator(d)].Start, f.Statements[d].End, true
+}
+
+// Block returns the lines of the innermost definition introduced by keyword,
+// e.g. "def" or "class", that contains the one-based physical line, from its
+// first decorator to the last statement of its body. It returns false if no
+// such definition contains the line.
+func (f *File) Block(line int, keyword string) (start, end int, ok bool) {
+	for i := len(f.Statements) - 1; i >= 0; i-- {
+		if f.Statements[i].Keyword != keyword {
+			continue
+		}
+		start, end := f.Statements[f.firstDecorator(i)].Start, f.Statements[f.lastInBody(i)].End
+		if start <= line && line <= end {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// firstDecorator returns the index of the first decorator of statement i, or i
+// if it isn't decorated
+func (f *File) firstDecorator(i int) int {
+	for i > 0 && f.Statements[i-1].Keyword == "@" && f.Statements[i-1].Indent == f.Statements[i].Indent {
+		i--
+	}
+	return i
+}
+
+// lastInBody returns the index of the last statement indented under statement
+// i, or i if there is none
+func (f *File) lastInBody(i int) int {
+	last := i
+	for last+1 < len(f.Statements) && f.Statements[last+1].Indent > f.Statements[i].Indent {
+		last++
+	}
+	return last
+}
+
+// scanner carries the tokenizer state from one physical line to the next
+type scanner struct {
+	depth int
+	// quote is the delimiter of the string being scanned, e.g. `"` or `'''`
+	quote string
+}
+
+// continues scans a physical line and reports whether the logical line goes on
+// past its end
+func (s *scanner) continues(line string) bool {
+	line = strings.TrimRight(line, "\r\n")
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		if s.quote != "" {
+			switch {
+			case c == '\\':
+				i++
+			case strings.HasPrefix(line[i:], s.quote):
+				i += len(s.quote) - 1
+				s.quote = ""
+			}
+			continue
+		}
+
+		switch c {
+		case '#':
+			return s.depth > 0
+		case '"', '\'':
+			s.quote = string(c)
+			if strings.HasPrefix(line[i:], strings.Repeat(string(c), 3)) {
+				s.quote = strings.Repeat(string(c), 3)
+				i += 2
+			}
+		case '(', '[', '{':
+			s.depth++
+		case ')', ']', '}':
+			if s.depth > 0 {
+				s.depth--
+			}
+		case '\\':
+			if i == len(line)-1 {
+				return true
+			}
+		}
+	}
+
+	// A single-quoted string can't span lines; leave it to the next statement
+	if len(s.quote) == 1 {
+		s.quote = ""
+	}
+	return s.quote != "" || s.depth > 0
+}
+
+// indentWidth returns the width of the leading whitespace of a line
+func indentWidth(line string) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += tabSize - width%tabSize
+		case '\f':
+			width = 0
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// keyword returns the first word of a statement, skipping "async", or "@" for a
+// decorator
+func keyword(trimmed string) string {
+	if trimmed[0] == '@' {
+		return "@"
+	}
+	end := strings.IndexFunc(trimmed, func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	if end < 0 {
+		return trimmed
+	}
+	if word := trimmed[:end]; word != "async" {
+		return word
+	}
+	if rest := strings.TrimLeft(trimmed[end:], " \t"); rest != "" {
+		return keyword(rest)
+	}
+	return "async"
+}
@@ -0,0 +1,184 @@
+package pyscan
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanStatements(t *testing.T) {
+	src := `import os  # comment (
+
+@decorator
+def f(a,
+      b):
+    """Docstring
+    with (brackets"""
+    x = [1, "]",
+         2]  # ]
+    y = 1 + \
+        2
+
+    return g('(',
+             a)
+`
+	f := Scan([]byte(src))
+
+	expected := []Statement{
+		{Start: 1, End: 1, Indent: 0, Keyword: "import"},
+		{Start: 3, End: 3, Indent: 0, Keyword: "@"},
+		{Start: 4, End: 5, Indent: 0, Keyword: "def"},
+		{Start: 6, End: 7, Indent: 4, Keyword: ""},
+		{Start: 8, End: 9, Indent: 4, Keyword: "x"},
+		{Start: 10, End: 11, Indent: 4, Keyword: "y"},
+		{Start: 13, End: 14, Indent: 4, Keyword: "return"},
+	}
+	if !reflect.DeepEqual(f.Statements, expected) {
+		t.Errorf("Expected statements %+v, got %+v", expected, f.Statements)
+	}
+	if f.LineCount() != 14 {
+		t.Errorf("Expected 14 lines, got %d", f.LineCount())
+	}
+}
+
+func TestStatementAt(t *testing.T) {
+	f := Scan([]byte("call(\n    a,\n)\n\n# comment\nb = 2"))
+
+	tests := []struct {
+		line  int
+		start int
+		end   int
+		ok    bool
+	}{
+		{line: 1, start: 1, end: 3, ok: true},
+		{line: 2, start: 1, end: 3, ok: true},
+		{line: 3, start: 1, end: 3, ok: true},
+		{line: 4},
+		{line: 5},
+		{line: 6, start: 6, end: 6, ok: true},
+		{line: 0},
+		{line: 7},
+	}
+
+	for _, tt := range tests {
+		stmt, ok := f.StatementAt(tt.line)
+		if ok != tt.ok || stmt.Start != tt.start || stmt.End != tt.end {
+			t.Errorf("StatementAt(%d): expected [%d, %d] %v, got [%d, %d] %v", tt.line, tt.start, tt.end, tt.ok, stmt.Start, stmt.End, ok)
+		}
+	}
+}
+
+func TestScanInvalidSource(t *testing.T) {
+	// An unterminated single-quoted string ends at its line and a stray
+	// closing bracket is ignored
+	f := Scan([]byte("a = 'open\nb = 1)\nc = 2\n"))
+
+	if len(f.Statements) != 3 {
+		t.Errorf("Expected 3 statements, got %+v", f.Statements)
+	}
+}
+
+func TestScanIndent(t *testing.T) {
+	f := Scan([]byte("if x:\n\ty = 1\n  \tz = 2\n"))
+
+	for i, indent := range []int{0, 8, 8} {
+		if got := f.Statements[i].Indent; got != indent {
+			t.Errorf("Statement %d: expected indent %d, got %d", i, indent, got)
+		}
+	}
+}
+
+// blockSource has nested and decorated definitions
+const blockSource = `import os
+
+
+@dataclass
+@other(
+    x=1,
+)
+class A:
+    y = 1
+
+    async def f(self):
+        return [
+            1,
+        ]
+
+    # trailing comment
+z = 2
+`
+
+func TestSpan(t *testing.T) {
+	f := Scan([]byte(blockSource))
+
+	tests := []struct {
+		line  int
+		start int
+		end   int
+		ok    bool
+	}{
+		{line: 1, start: 1, end: 1, ok: true},
+		{line: 2},
+		{line: 4, start: 4, end: 8, ok: true},
+		{line: 6, start: 4, end: 8, ok: true},
+		{line: 8, start: 4, end: 8, ok: true},
+		{line: 13, start: 12, end: 14, ok: true},
+	}
+
+	for _, tt := range tests {
+		start, end, ok := f.Span(tt.line)
+		if ok != tt.ok || start != tt.start || end != tt.end {
+			t.Errorf("Span(%d): expected [%d, %d] %v, got [%d, %d] %v", tt.line, tt.start, tt.end, tt.ok, start, end, ok)
+		}
+	}
+}
+
+func TestBlock(t *testing.T) {
+	f := Scan([]byte(blockSource))
+
+	tests := []struct {
+		line    int
+		keyword string
+		start   int
+		end     int
+		ok      bool
+	}{
+		{line: 13, keyword: "def", start: 11, end: 14, ok: true},
+		{line: 13, keyword: "class", start: 4, end: 14, ok: true},
+		{line: 5, keyword: "class", start: 4, end: 14, ok: true},
+		{line: 9, keyword: "def"},
+		{line: 17, keyword: "class"},
+	}
+
+	for _, tt := range tests {
+		start, end, ok := f.Block(tt.line, tt.keyword)
+		if ok != tt.ok || start != tt.start || end != tt.end {
+			t.Errorf("Block(%d, %q): expected [%d, %d] %v, got [%d, %d] %v", tt.line, tt.keyword, tt.start, tt.end, tt.ok, start, end, ok)
+		}
+	}
+
+	if keyword := f.Statements[5].Keyword; keyword != "def" {
+		t.Errorf("Expected async def to have keyword def, got %q", keyword)
+	}
+}
+
+func TestHasCode(t *testing.T) {
+	f := Scan([]byte("x = 1\n\n# comment\ns = '''\n# not a comment\n'''\n"))
+
+	tests := []struct {
+		start    int
+		end      int
+		expected bool
+	}{
+		{start: 1, end: 1, expected: true},
+		{start: 2, end: 3, expected: false},
+		{start: 2, end: 4, expected: true},
+		{start: 5, end: 5, expected: true},
+		{start: 7, end: 9, expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := f.HasCode(tt.start, tt.end); got != tt.expected {
+			t.Errorf("HasCode(%d, %d): expected %v, got %v", tt.start, tt.end, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package ruff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// Location is a one-based row and column position reported by ruff check
+type Location struct {
+	Row    int `json:"row"`
+	Column int `json:"column"`
+}
+
+// Edit is a single text replacement that is part of a fix
+type Edit struct {
+	Content     string   `json:"content"`
+	Location    Location `json:"location"`
+	EndLocation Location `json:"end_location"`
+}
+
+// Fix is the fix ruff suggests for a diagnostic
+type Fix struct {
+	Applicability string `json:"applicability"`
+	Message       string `json:"message"`
+	Edits         []Edit `json:"edits"`
+}
+
+// Diagnostic is a single violation reported by ruff check --output-format json
+type Diagnostic struct {
+	Code        string   `json:"code"`
+	Message     string   `json:"message"`
+	Filename    string   `json:"filename"`
+	Location    Location `json:"location"`
+	EndLocation Location `json:"end_location"`
+	Fix         *Fix     `json:"fix"`
+	URL         string   `json:"url"`
+}
+
+// Check runs ruff check on the changed files and returns every diagnostic found.
+// Use FilterDiagnostics to keep only those on changed lines.
+func (r *Ruff) Check(fileChanges []git.FileChanges) ([]Diagnostic, error) {
+	if len(fileChanges) == 0 {
+		return []Diagnostic{}, nil
+	}
+
+	args := []string{"check", "--output-format", "json", "--no-fix", "--exit-zero", "--force-exclude"}
+	for _, fc := range fileChanges {
+		args = append(args, filepath.Join(r.repoRoot, fc.FilePath))
+	}
+
+	if r.verbose {
+		fmt.Fprintf(r.log, "Running: ruff %s\n", strings.Join(args, " "))
+	}
+
+	cmd := exec.Command("ruff", args...)
+	cmd.Dir = r.repoRoot
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ruff check failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var diagnostics []Diagnostic
+	if err := json.Unmarshal(stdout.Bytes(), &diagnostics); err != nil {
+		return nil, fmt.Errorf("failed to parse ruff check output: %w", err)
+	}
+
+	return diagnostics, nil
+}
+
+// FilterDiagnostics keeps only the diagnostics whose location intersects one of
+// the changed line ranges of their file
+func (r *Ruff) FilterDiagnostics(diagnostics []Diagnostic, fileChanges []git.FileChanges) []Diagnostic {
+	rangesByFile := r.rangesByAbsolutePath(fileChanges)
+
+	filtered := []Diagnostic{}
+	for _, d := range diagnostics {
+		endRow := d.EndLocation.Row
+		if endRow < d.Location.Row {
+			endRow = d.Location.Row
+		}
+		if intersectsAny(d.Location.Row, endRow, rangesByFile[r.absolutePath(d.Filename)]) {
+			filtered = append(filtered, d)
+		}
+	}
+
+	return filtered
+}
+
+// ApplyFixes applies the fixes of the given diagnostics whose edits all lie inside
+// the changed line ranges of their file. Unsafe fixes are only applied when
+// unsafe is set. It returns the number of fixes applied.
+func (r *Ruff) ApplyFixes(diagnostics []Diagnostic, fileChanges []git.FileChanges, unsafe bool) (int, error) {
+	rangesByFile := r.rangesByAbsolutePath(fileChanges)
+
+	fixesByFile := make(map[string][]*Fix)
+	var files []string
+	for _, d := range diagnostics {
+		if d.Fix == nil || len(d.Fix.Edits) == 0 || !fixApplicable(d.Fix, unsafe) {
+			continue
+		}
+
+		path := r.absolutePath(d.Filename)
+		if !editsWithinRanges(d.Fix.Edits, rangesByFile[path]) {
+			if r.verbose {
+				fmt.Fprintf(r.log, "Skipping fix for %s at %s:%d: edits reach outside changed lines\n", d.Code, d.Filename, d.Location.Row)
+			}
+			continue
+		}
+
+		if _, ok := fixesByFile[path]; !ok {
+			files = append(files, path)
+		}
+		fixesByFile[path] = append(fixesByFile[path], d.Fix)
+	}
+
+	applied := 0
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return applied, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		fixed, count := applyFixesToContent(string(content), fixesByFile[path])
+		if count == 0 {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return applied, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if err := os.WriteFile(path, []byte(fixed), info.Mode().Perm()); err != nil {
+			return applied, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+
+		applied += count
+	}
+
+	return applied, nil
+}
+
+// rangesByAbsolutePath indexes the changed line ranges by absolute file path
+func (r *Ruff) rangesByAbsolutePath(fileChanges []git.FileChanges) map[string][]git.LineRange {
+	rangesByFile := make(map[string][]git.LineRange)
+	for _, fc := range fileChanges {
+		path := r.absolutePath(fc.FilePath)
+		rangesByFile[path] = append(rangesByFile[path], fc.LineRanges...)
+	}
+	return rangesByFile
+}
+
+// absolutePath resolves a path relative to the repository root
+func (r *Ruff) absolutePath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(r.repoRoot, path)
+}
+
+// fixApplicable reports whether a fix may be applied automatically
+func fixApplicable(fix *Fix, unsafe bool) bool {
+	switch fix.Applicability {
+	case "safe":
+		return true
+	case "unsafe":
+		return unsafe
+	default:
+		return false
+	}
+}
+
+// intersectsAny reports whether the rows start to end overlap any of the ranges
+func intersectsAny(start, end int, ranges []git.LineRange) bool {
+	for _, lr := range ranges {
+		if start <= lr.End && end >= lr.Start {
+			return true
+		}
+	}
+	return false
+}
+
+// editsWithinRanges reports whether every edit lies inside a single changed range
+func editsWithinRanges(edits []Edit, ranges []git.LineRange) bool {
+	for _, e := range edits {
+		start, end := e.Location.Row, e.EndLocation.Row
+		// An edit ending at the first column of a row ends on the previous row
+		if end > start && e.EndLocation.Column == 1 {
+			end--
+		}
+
+		inside := false
+		for _, lr := range ranges {
+			if start >= lr.Start && end <= lr.End {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return false
+		}
+	}
+	return true
+}
+
+// applyFixesToContent applies fixes from the bottom of the file up, skipping any
+// fix that overlaps one already applied. It returns the new content and the
+// number of fixes applied.
+func applyFixesToContent(content string, fixes []*Fix) (string, int) {
+	runes := []rune(content)
+	lineStarts := []int{0}
+	for i, c := range runes {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	offset := func(loc Location) int {
+		if loc.Row-1 >= len(lineStarts) {
+			return len(runes)
+		}
+		o := lineStarts[loc.Row-1] + loc.Column - 1
+		if o > len(runes) {
+			return len(runes)
+		}
+		return o
+	}
+
+	type span struct {
+		start, end int
+		edits      []Edit
+	}
+
+	var spans []span
+	for _, fix := range fixes {
+		s := span{start: len(runes), end: 0, edits: fix.Edits}
+		for _, e := range fix.Edits {
+			if o := offset(e.Location); o < s.start {
+				s.start = o
+			}
+			if o := offset(e.EndLocation); o > s.end {
+				s.end = o
+			}
+		}
+		spans = append(spans, s)
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].start > spans[j].start
+	})
+
+	applied := 0
+	limit := len(runes)
+	for _, s := range spans {
+		if s.end > limit {
+			continue
+		}
+
+		edits := make([]Edit, len(s.edits))
+		copy(edits, s.edits)
+		sort.SliceStable(edits, func(i, j int) bool {
+			return offset(edits[i].Location) > offset(edits[j].Location)
+		})
+
+		for _, e := range edits {
+			start, end := offset(e.Location), offset(e.EndLocation)
+			replaced := append([]rune{}, runes[:start]...)
+			replaced = append(replaced, []rune(e.Content)...)
+			runes = append(replaced, runes[end:]...)
+		}
+
+		limit = s.start
+		applied++
+	}
+
+	return string(runes), applied
+}
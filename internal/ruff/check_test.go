@@ -0,0 +1,145 @@
+package ruff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/testutil"
+)
+
+func TestCheckParsesDiagnostics(t *testing.T) {
+	repoRoot := t.TempDir()
+	testutil.InstallFakeTool(t, "ruff", `echo '[{"code":"F401","message":"unused import","filename":"`+repoRoot+`/main.py","location":{"row":1,"column":8},"end_location":{"row":1,"column":10},"fix":{"applicability":"safe","message":"Remove import","edits":[{"content":"","location":{"row":1,"column":1},"end_location":{"row":2,"column":1}}]},"url":"https://docs.astral.sh/ruff/rules/unused-import"},{"code":null,"message":"SyntaxError","filename":"`+repoRoot+`/main.py","location":{"row":5,"column":1},"end_location":{"row":5,"column":2},"fix":null}]'`)
+
+	r := New(repoRoot, false, false)
+	diagnostics, err := r.Check([]git.FileChanges{{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}}}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %d", len(diagnostics))
+	}
+	if diagnostics[0].Code != "F401" || diagnostics[0].Fix == nil || len(diagnostics[0].Fix.Edits) != 1 {
+		t.Errorf("Unexpected first diagnostic: %+v", diagnostics[0])
+	}
+	if diagnostics[1].Code != "" || diagnostics[1].Fix != nil {
+		t.Errorf("Expected syntax error without code or fix, got %+v", diagnostics[1])
+	}
+}
+
+func TestCheckRuffFailure(t *testing.T) {
+	testutil.InstallFakeTool(t, "ruff", `echo "error: invalid config" >&2; exit 2`)
+
+	r := New(t.TempDir(), false, false)
+	if _, err := r.Check([]git.FileChanges{{FilePath: "main.py"}}); err == nil {
+		t.Errorf("Expected error from failing ruff check, got nil")
+	}
+}
+
+func TestFilterDiagnostics(t *testing.T) {
+	r := New("/repo", false, false)
+	fileChanges := []git.FileChanges{
+		{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 5, End: 10}}},
+	}
+
+	diagnostics := []Diagnostic{
+		{Code: "A", Filename: "/repo/main.py", Location: Location{Row: 1}, EndLocation: Location{Row: 1}},
+		{Code: "B", Filename: "/repo/main.py", Location: Location{Row: 7}, EndLocation: Location{Row: 7}},
+		{Code: "C", Filename: "/repo/main.py", Location: Location{Row: 3}, EndLocation: Location{Row: 5}},
+		{Code: "D", Filename: "/repo/main.py", Location: Location{Row: 11}, EndLocation: Location{Row: 12}},
+		{Code: "E", Filename: "/repo/other.py", Location: Location{Row: 7}, EndLocation: Location{Row: 7}},
+	}
+
+	filtered := r.FilterDiagnostics(diagnostics, fileChanges)
+
+	var codes string
+	for _, d := range filtered {
+		codes += d.Code
+	}
+	if codes != "BC" {
+		t.Errorf("Expected diagnostics B and C to remain, got %q", codes)
+	}
+}
+
+func TestEditsWithinRanges(t *testing.T) {
+	ranges := []git.LineRange{{Start: 3, End: 4}}
+
+	tests := []struct {
+		name     string
+		edit     Edit
+		expected bool
+	}{
+		{"inside", Edit{Location: Location{Row: 3, Column: 5}, EndLocation: Location{Row: 4, Column: 2}}, true},
+		{"whole line deletion", Edit{Location: Location{Row: 4, Column: 1}, EndLocation: Location{Row: 5, Column: 1}}, true},
+		{"reaches below", Edit{Location: Location{Row: 4, Column: 1}, EndLocation: Location{Row: 5, Column: 3}}, false},
+		{"above", Edit{Location: Location{Row: 2, Column: 1}, EndLocation: Location{Row: 3, Column: 1}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := editsWithinRanges([]Edit{tt.edit}, ranges); got != tt.expected {
+				t.Errorf("editsWithinRanges() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplyFixesToContent(t *testing.T) {
+	content := "import os\nx = 1\ny = 'é'  \n"
+	fixes := []*Fix{
+		// Delete the first line
+		{Edits: []Edit{{Content: "", Location: Location{Row: 1, Column: 1}, EndLocation: Location{Row: 2, Column: 1}}}},
+		// Strip trailing whitespace after a non-ASCII character
+		{Edits: []Edit{{Content: "", Location: Location{Row: 3, Column: 8}, EndLocation: Location{Row: 3, Column: 10}}}},
+		// Overlaps the deletion above and must be skipped
+		{Edits: []Edit{{Content: "import sys", Location: Location{Row: 1, Column: 1}, EndLocation: Location{Row: 1, Column: 10}}}},
+	}
+
+	result, applied := applyFixesToContent(content, fixes)
+
+	expected := "x = 1\ny = 'é'\n"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+	if applied != 2 {
+		t.Errorf("Expected 2 fixes applied, got %d", applied)
+	}
+}
+
+func TestApplyFixesOnlyInsideChangedRanges(t *testing.T) {
+	repoRoot := t.TempDir()
+	pyFile := filepath.Join(repoRoot, "main.py")
+	if err := os.WriteFile(pyFile, []byte("import os\nimport sys\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	deleteLine := func(row int) *Fix {
+		return &Fix{Applicability: "safe", Edits: []Edit{{Location: Location{Row: row, Column: 1}, EndLocation: Location{Row: row + 1, Column: 1}}}}
+	}
+	diagnostics := []Diagnostic{
+		{Code: "F401", Filename: pyFile, Location: Location{Row: 1, Column: 8}, Fix: deleteLine(1)},
+		{Code: "F401", Filename: pyFile, Location: Location{Row: 2, Column: 8}, Fix: deleteLine(2)},
+		{Code: "X", Filename: pyFile, Location: Location{Row: 2, Column: 1}, Fix: &Fix{Applicability: "unsafe", Edits: deleteLine(2).Edits}},
+	}
+	fileChanges := []git.FileChanges{{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 2, End: 2}}}}
+
+	r := New(repoRoot, false, false)
+	applied, err := r.ApplyFixes(diagnostics, fileChanges, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if applied != 1 {
+		t.Errorf("Expected 1 fix applied, got %d", applied)
+	}
+
+	content, err := os.ReadFile(pyFile)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(content) != "import os\n" {
+		t.Errorf("Expected only the changed line to be fixed, got %q", content)
+	}
+}
@@ -0,0 +1,44 @@
+package ruff
+
+import (
+	"strconv"
+
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// Position is a one-based line and column in a file. Columns count Unicode
+// code points, as ruff does.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Range is a region of a file passed to ruff format --range. The start is
+// inclusive and the end is exclusive, matching ruff's semantics.
+type Range struct {
+	Start Position
+	End   Position
+}
+
+// NewRange returns the range covering every line of lr, from the first column
+// of its start line up to the first column of the line after its end
+func NewRange(lr git.LineRange) Range {
+	return Range{
+		Start: Position{Line: lr.Start, Column: 1},
+		End:   Position{Line: lr.End + 1, Column: 1},
+	}
+}
+
+// String encodes the range in ruff's <start_line>:<start_column>-<end_line>:<end_column>
+// syntax, leaving out columns that are 1 since ruff defaults them to the line start
+func (r Range) String() string {
+	return formatPosition(r.Start) + "-" + formatPosition(r.End)
+}
+
+// formatPosition encodes a position as "line" or "line:column"
+func formatPosition(p Position) string {
+	if p.Column <= 1 {
+		return strconv.Itoa(p.Line)
+	}
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
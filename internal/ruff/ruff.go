@@ -1,13 +1,19 @@
 package ruff
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
-	"strconv"
 	"strings"
+	"time"
 
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
 	"github.com/horiagug/ruff-format-changes/internal/git"
 )
 
@@ -16,6 +22,10 @@ type Ruff struct {
 	dryRun   bool
 	verbose  bool
 	repoRoot string
+	jobs     int
+	log      io.Writer
+	// stdin formats each file in memory and writes it once, see formatFileInMemory
+	stdin bool
 }
 
 // New creates a new Ruff instance
@@ -24,6 +34,8 @@ func New(repoRoot string, dryRun, verbose bool) *Ruff {
 		dryRun:   dryRun,
 		verbose:  verbose,
 		repoRoot: repoRoot,
+		jobs:     runtime.NumCPU(),
+		log:      os.Stdout,
 	}
 }
 
@@ -36,6 +48,32 @@ func CheckRuffInstalled() error {
 	return nil
 }
 
+// Name returns the name of the formatter
+func (r *Ruff) Name() string {
+	return "ruff"
+}
+
+// CheckInstalled verifies that ruff is installed and accessible
+func (r *Ruff) CheckInstalled() error {
+	return CheckRuffInstalled()
+}
+
+// SetJobs sets how many files are formatted in parallel
+func (r *Ruff) SetJobs(jobs int) {
+	r.jobs = jobs
+}
+
+// SetLog sets where progress messages are written
+func (r *Ruff) SetLog(log io.Writer) {
+	r.log = log
+}
+
+// SetStdin makes FormatFilesByLineRanges pipe each file through ruff in memory
+// and write the result once, instead of letting ruff rewrite the file per range
+func (r *Ruff) SetStdin(stdin bool) {
+	r.stdin = stdin
+}
+
 // GetAbsolutePaths converts relative file paths to absolute paths
 func (r *Ruff) GetAbsolutePaths(files []string) []string {
 	var absolute []string
@@ -46,102 +84,190 @@ func (r *Ruff) GetAbsolutePaths(files []string) []string {
 	return absolute
 }
 
-// FormatFilesByLineRanges runs ruff format on specific line ranges in files
-func (r *Ruff) FormatFilesByLineRanges(fileChanges []git.FileChanges) error {
+// FormatFilesByLineRanges runs ruff format on specific line ranges in files and
+// reports the outcome of every range. Up to jobs files are formatted in parallel,
+// while the ranges of each file are formatted one after another.
+func (r *Ruff) FormatFilesByLineRanges(fileChanges []git.FileChanges) (*formatter.Report, error) {
+	report := &formatter.Report{Formatter: r.Name(), DryRun: r.dryRun, Files: []formatter.FileResult{}}
+	started := time.Now()
+
 	if len(fileChanges) == 0 {
 		if r.verbose {
-			fmt.Println("No changed lines to format")
+			fmt.Fprintln(r.log, "No changed lines to format")
 		}
-		return nil
+		return report, nil
 	}
 
 	if r.verbose {
-		fmt.Printf("Found %d Python file(s) with changed lines:\n", len(fileChanges))
+		fmt.Fprintf(r.log, "Found %d Python file(s) with changed lines:\n", len(fileChanges))
 		for _, fc := range fileChanges {
-			fmt.Printf("  - %s\n", fc.FilePath)
+			fmt.Fprintf(r.log, "  - %s\n", fc.FilePath)
 			for _, lr := range fc.LineRanges {
 				if lr.Start == lr.End {
-					fmt.Printf("    Line %d\n", lr.Start)
+					fmt.Fprintf(r.log, "    Line %d\n", lr.Start)
 				} else {
-					fmt.Printf("    Lines %d-%d\n", lr.Start, lr.End)
+					fmt.Fprintf(r.log, "    Lines %d-%d\n", lr.Start, lr.End)
 				}
 			}
 		}
 	}
 
-	for _, fc := range fileChanges {
-		absPath := filepath.Join(r.repoRoot, fc.FilePath)
+	report.Files = formatter.FormatFiles(fileChanges, r.jobs, r.log, r.formatFile)
 
-		// Sort line ranges in descending order (highest line numbers first)
-		// This prevents earlier format operations from shifting line numbers of later ranges
-		sortedRanges := make([]git.LineRange, len(fc.LineRanges))
-		copy(sortedRanges, fc.LineRanges)
-		sort.Slice(sortedRanges, func(i, j int) bool {
-			return sortedRanges[i].Start > sortedRanges[j].Start
-		})
+	report.Duration = time.Since(started)
+	return report, formatter.FailureError(report)
+}
 
-		for _, lineRange := range sortedRanges {
-			if err := r.formatFileWithRange(absPath, lineRange); err != nil {
-				return err
-			}
+// formatFile formats the changed ranges of a single file bottom-up. Once a range
+// fails, the remaining ranges of the file are skipped. Log messages are written to log.
+func (r *Ruff) formatFile(fc git.FileChanges, log io.Writer) formatter.FileResult {
+	if r.stdin {
+		return r.formatFileInMemory(fc, log)
+	}
+
+	absPath := filepath.Join(r.repoRoot, fc.FilePath)
+	result := formatter.FileResult{FilePath: fc.FilePath, BeforeHash: formatter.HashFile(absPath)}
+	started := time.Now()
+
+	// Sort line ranges in descending order (highest line numbers first)
+	// This prevents earlier format operations from shifting line numbers of later ranges
+	sortedRanges := make([]git.LineRange, len(fc.LineRanges))
+	copy(sortedRanges, fc.LineRanges)
+	sort.Slice(sortedRanges, func(i, j int) bool {
+		return sortedRanges[i].Start > sortedRanges[j].Start
+	})
+
+	var diffs []string
+	for _, lineRange := range sortedRanges {
+		rangeResult, diff := r.formatFileWithRange(absPath, lineRange, log)
+		result.Ranges = append(result.Ranges, rangeResult)
+		if diff != "" {
+			diffs = append(diffs, diff)
+		}
+		if rangeResult.Status == formatter.StatusFailed {
+			break
 		}
 	}
 
-	if !r.dryRun && r.verbose {
-		fmt.Printf("\nSuccessfully formatted changed lines\n")
+	// Report ranges top-down, in the order they appear in the file
+	sort.Slice(result.Ranges, func(i, j int) bool {
+		return result.Ranges[i].Range.Start < result.Ranges[j].Range.Start
+	})
+	for i := len(diffs) - 1; i >= 0; i-- {
+		result.Diff += diffs[i]
 	}
 
-	return nil
+	result.AfterHash = formatter.HashFile(absPath)
+	result.Duration = time.Since(started)
+	return result
 }
 
-// formatFileWithRange formats a specific line range in a file
-func (r *Ruff) formatFileWithRange(filePath string, lineRange git.LineRange) error {
+// formatFileWithRange formats a specific line range in a file. In dry-run mode
+// the diff ruff would apply is returned as well.
+func (r *Ruff) formatFileWithRange(filePath string, lineRange git.LineRange, log io.Writer) (formatter.RangeResult, string) {
 	args := []string{"format"}
 
 	if r.dryRun {
 		args = append(args, "--check", "--diff")
 	}
 
-	rangeArg := formatRangeArg(lineRange.Start, lineRange.End)
-	args = append(args, "--range", rangeArg)
+	args = append(args, "--range", formatRangeArg(NewRange(lineRange)))
 	args = append(args, filePath)
 
 	if r.verbose {
-		fmt.Printf("Running: ruff %s\n", strings.Join(args, " "))
+		fmt.Fprintf(log, "Running: ruff %s\n", strings.Join(args, " "))
 	}
 
+	before := formatter.HashFile(filePath)
+	started := time.Now()
+
 	cmd := exec.Command("ruff", args...)
 	cmd.Dir = r.repoRoot
 
-	output, err := cmd.CombinedOutput()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
 
-	if len(output) > 0 {
-		fmt.Println(string(output))
+	result := formatter.RangeResult{
+		Range:    lineRange,
+		Stderr:   stderr.String(),
+		Duration: time.Since(started),
 	}
 
-	if err != nil && r.dryRun {
-		if strings.Contains(string(output), "would be reformatted") ||
-			strings.Contains(string(output), "would reformat") {
-			return nil
+	var exitErr *exec.ExitError
+	switch {
+	case err != nil && r.dryRun && errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		// ruff format --check exits with 1 when the file would be reformatted
+		result.Status = formatter.StatusWouldReformat
+		return result, stdout.String()
+	case err != nil:
+		result.Status = formatter.StatusFailed
+		result.Error = fmt.Sprintf("ruff format failed: %v", err)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			result.Error += ": " + msg
 		}
-		if strings.Contains(string(output), "error:") {
-			return fmt.Errorf("ruff format failed: %w", err)
-		}
-		return nil
-	} else if err != nil && !r.dryRun {
-		if strings.Contains(string(output), "error:") {
-			return fmt.Errorf("ruff format failed: %w", err)
+	case !r.dryRun && formatter.HashFile(filePath) != before:
+		result.Status = formatter.StatusFormatted
+	default:
+		result.Status = formatter.StatusUnchanged
+	}
+
+	return result, ""
+}
+
+// FormatContentByLineRanges formats content in memory as if it were stored at
+// filePath, which is only used by ruff to resolve configuration. The formatted
+// content is returned; nothing is written to disk regardless of dry-run mode.
+func (r *Ruff) FormatContentByLineRanges(filePath string, content []byte, ranges []git.LineRange) ([]byte, error) {
+	sortedRanges := make([]git.LineRange, len(ranges))
+	copy(sortedRanges, ranges)
+	sort.Slice(sortedRanges, func(i, j int) bool {
+		return sortedRanges[i].Start > sortedRanges[j].Start
+	})
+
+	for _, lineRange := range sortedRanges {
+		formatted, _, err := r.formatContentWithRange(filePath, content, lineRange, r.log)
+		if err != nil {
+			return nil, err
 		}
-		return nil
+		content = formatted
 	}
 
-	return nil
+	return content, nil
 }
 
-// formatRangeArg formats the range argument for ruff format (e.g., "12:15" or "12")
-func formatRangeArg(start, end int) string {
-	if start == end {
-		return strconv.Itoa(start)
+// formatContentWithRange pipes content through ruff format for a single line range
+// and returns the formatted content along with ruff's stderr
+func (r *Ruff) formatContentWithRange(filePath string, content []byte, lineRange git.LineRange, log io.Writer) ([]byte, string, error) {
+	args := []string{
+		"format",
+		"--stdin-filename", filePath,
+		"--range", formatRangeArg(NewRange(lineRange)),
+		"-",
+	}
+
+	if r.verbose {
+		fmt.Fprintf(log, "Running: ruff %s\n", strings.Join(args, " "))
+	}
+
+	cmd := exec.Command("ruff", args...)
+	cmd.Dir = r.repoRoot
+	cmd.Stdin = bytes.NewReader(content)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, stderr.String(), fmt.Errorf("ruff format failed for %s: %w: %s", filePath, err, strings.TrimSpace(stderr.String()))
 	}
-	return strconv.Itoa(start) + ":" + strconv.Itoa(end)
+
+	return stdout.Bytes(), stderr.String(), nil
+}
+
+// formatRangeArg formats the range argument for ruff format (e.g., "12-16" for lines 12 to 15)
+func formatRangeArg(r Range) string {
+	return r.String()
 }
@@ -1,10 +1,16 @@
 package ruff
 
 import (
+	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
 	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/testutil"
 )
 
 func TestGetAbsolutePaths(t *testing.T) {
@@ -183,7 +189,7 @@ func TestFormatFilesByLineRangesEmptyInput(t *testing.T) {
 	r := New("/tmp/repo", false, false)
 	fileChanges := []git.FileChanges{}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Errorf("Expected no error for empty input, got %v", err)
 	}
@@ -193,7 +199,7 @@ func TestFormatFilesByLineRangesEmptyInputVerbose(t *testing.T) {
 	r := New("/tmp/repo", false, true)
 	fileChanges := []git.FileChanges{}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Errorf("Expected no error for empty input with verbose, got %v", err)
 	}
@@ -213,7 +219,7 @@ func TestFormatFilesByLineRangesSingleFile(t *testing.T) {
 		},
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		// We expect an error because ruff command might not exist,
 		// but the method structure should be sound
@@ -240,7 +246,7 @@ func TestFormatFilesByLineRangesMultipleFiles(t *testing.T) {
 		},
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Logf("Got expected error (ruff may not be installed): %v", err)
 	}
@@ -271,7 +277,7 @@ func TestFormatFilesByLineRangesDryRunMode(t *testing.T) {
 		t.Errorf("Expected dryRun to be true")
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Logf("Got expected error (ruff may not be installed): %v", err)
 	}
@@ -293,7 +299,7 @@ func TestFormatFilesByLineRangesNormalMode(t *testing.T) {
 		t.Errorf("Expected dryRun to be false")
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Logf("Got expected error (ruff may not be installed): %v", err)
 	}
@@ -311,7 +317,7 @@ func TestFormatFilesByLineRangesSingleLineRange(t *testing.T) {
 		},
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Logf("Got expected error (ruff may not be installed): %v", err)
 	}
@@ -331,7 +337,7 @@ func TestFormatFilesByLineRangesMultipleLineRanges(t *testing.T) {
 		},
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Logf("Got expected error (ruff may not be installed): %v", err)
 	}
@@ -356,7 +362,7 @@ func TestFormatFilesByLineRangesNestedPath(t *testing.T) {
 		t.Errorf("Expected absolute path, got %s", expectedPath)
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Logf("Got expected error (ruff may not be installed): %v", err)
 	}
@@ -379,7 +385,7 @@ func TestFormatFilesByLineRangesVerboseOutput(t *testing.T) {
 		t.Errorf("Expected verbose to be true")
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Logf("Got expected error (ruff may not be installed): %v", err)
 	}
@@ -412,7 +418,7 @@ func TestFormatFilesByLineRangesMultipleFilesMultipleRanges(t *testing.T) {
 		},
 	}
 
-	err := r.FormatFilesByLineRanges(fileChanges)
+	_, err := r.FormatFilesByLineRanges(fileChanges)
 	if err != nil {
 		t.Logf("Got expected error (ruff may not be installed): %v", err)
 	}
@@ -421,16 +427,16 @@ func TestFormatFilesByLineRangesMultipleFilesMultipleRanges(t *testing.T) {
 // Tests for formatRangeArg helper function
 
 func TestFormatRangeArgSingleLine(t *testing.T) {
-	result := formatRangeArg(10, 10)
-	expected := "10"
+	result := formatRangeArg(NewRange(git.LineRange{Start: 10, End: 10}))
+	expected := "10-11"
 	if result != expected {
 		t.Errorf("Expected %s, got %s", expected, result)
 	}
 }
 
 func TestFormatRangeArgMultipleLines(t *testing.T) {
-	result := formatRangeArg(5, 15)
-	expected := "5:15"
+	result := formatRangeArg(NewRange(git.LineRange{Start: 5, End: 15}))
+	expected := "5-16"
 	if result != expected {
 		t.Errorf("Expected %s, got %s", expected, result)
 	}
@@ -442,16 +448,244 @@ func TestFormatRangeArgEdgeCases(t *testing.T) {
 		end      int
 		expected string
 	}{
-		{1, 1, "1"},
-		{1, 100, "1:100"},
-		{999, 999, "999"},
-		{50, 51, "50:51"},
+		{1, 1, "1-2"},
+		{1, 100, "1-101"},
+		{999, 999, "999-1000"},
+		{50, 51, "50-52"},
 	}
 
 	for _, tt := range tests {
-		result := formatRangeArg(tt.start, tt.end)
+		result := formatRangeArg(NewRange(git.LineRange{Start: tt.start, End: tt.end}))
 		if result != tt.expected {
 			t.Errorf("formatRangeArg(%d, %d): expected %s, got %s", tt.start, tt.end, tt.expected, result)
 		}
 	}
 }
+
+func TestFormatRangeArgColumns(t *testing.T) {
+	tests := []struct {
+		r        Range
+		expected string
+	}{
+		{Range{Start: Position{Line: 3, Column: 5}, End: Position{Line: 7, Column: 2}}, "3:5-7:2"},
+		{Range{Start: Position{Line: 3, Column: 1}, End: Position{Line: 4, Column: 9}}, "3-4:9"},
+		{Range{Start: Position{Line: 3, Column: 4}, End: Position{Line: 4, Column: 1}}, "3:4-4"},
+	}
+
+	for _, tt := range tests {
+		result := formatRangeArg(tt.r)
+		if result != tt.expected {
+			t.Errorf("formatRangeArg(%+v): expected %s, got %s", tt.r, tt.expected, result)
+		}
+	}
+}
+
+// parseRuffRange decodes a --range value following ruff's grammar
+// <start_line>[:<start_column>]-<end_line>[:<end_column>], where omitted
+// columns default to 1 and the end position is exclusive
+func parseRuffRange(t *testing.T, value string) Range {
+	t.Helper()
+	match := regexp.MustCompile(`^(\d+)(?::(\d+))?-(\d+)(?::(\d+))?$`).FindStringSubmatch(value)
+	if match == nil {
+		t.Fatalf("%q does not match ruff's range grammar", value)
+	}
+	number := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			t.Fatalf("Invalid position %q in %q", s, value)
+		}
+		return n
+	}
+	return Range{
+		Start: Position{Line: number(match[1]), Column: number(match[2])},
+		End:   Position{Line: number(match[3]), Column: number(match[4])},
+	}
+}
+
+func TestFormatRangeArgMatchesRuffGrammar(t *testing.T) {
+	for _, lr := range []git.LineRange{{Start: 1, End: 1}, {Start: 12, End: 15}, {Start: 5, End: 300}} {
+		r := parseRuffRange(t, formatRangeArg(NewRange(lr)))
+
+		// Every changed line must be covered from its first column, and the
+		// exclusive end must stop at the start of the line after the range
+		if r.Start != (Position{Line: lr.Start, Column: 1}) {
+			t.Errorf("Range for %+v starts at %+v, expected line %d column 1", lr, r.Start, lr.Start)
+		}
+		if r.End != (Position{Line: lr.End + 1, Column: 1}) {
+			t.Errorf("Range for %+v ends at %+v, expected line %d column 1", lr, r.End, lr.End+1)
+		}
+	}
+}
+
+func TestFormatContentByLineRangesWholeSpanWithRuff(t *testing.T) {
+	if err := CheckRuffInstalled(); err != nil {
+		t.Skip("ruff not installed")
+	}
+
+	// Both statements lie inside the multi-line range and must be formatted
+	content := []byte("a=1\nb=2\nc=3\n")
+	r := New(t.TempDir(), false, false)
+	result, err := r.FormatContentByLineRanges("main.py", content, []git.LineRange{{Start: 1, End: 2}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "a = 1\nb = 2\nc=3\n"
+	if string(result) != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+// Tests for in-memory formatting
+
+func TestFormatContentByLineRanges(t *testing.T) {
+	// The fake ruff appends the range argument it was given to stdin
+	testutil.InstallFakeTool(t, "ruff", `cat; for a in "$@"; do [ "$prev" = "--range" ] && echo "$a"; prev="$a"; done`)
+
+	r := New(t.TempDir(), false, false)
+	ranges := []git.LineRange{{Start: 1, End: 2}, {Start: 10, End: 10}}
+
+	result, err := r.FormatContentByLineRanges("main.py", []byte("x\n"), ranges)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Ranges are applied bottom-up so earlier edits don't shift later ones
+	expected := "x\n10-11\n1-3\n"
+	if string(result) != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestFormatContentByLineRangesError(t *testing.T) {
+	testutil.InstallFakeTool(t, "ruff", `echo "error: Failed to parse" >&2; exit 2`)
+
+	r := New(t.TempDir(), false, false)
+	_, err := r.FormatContentByLineRanges("main.py", []byte("x\n"), []git.LineRange{{Start: 1, End: 1}})
+	if err == nil {
+		t.Fatalf("Expected error from failing ruff, got nil")
+	}
+	if !strings.Contains(err.Error(), "Failed to parse") {
+		t.Errorf("Expected ruff stderr in error, got %v", err)
+	}
+}
+
+// Tests for the formatting report
+
+func TestFormatFilesByLineRangesReport(t *testing.T) {
+	repoRoot := t.TempDir()
+	pyFile := filepath.Join(repoRoot, "main.py")
+	if err := os.WriteFile(pyFile, []byte("x=1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	// The fake ruff rewrites the file only for the range starting at line 1
+	testutil.InstallFakeTool(t, "ruff", `for a in "$@"; do f="$a"; done
+case "$*" in *"--range 1"*) echo "x = 1" > "$f";; esac`)
+
+	r := New(repoRoot, false, false)
+	report, err := r.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 5, End: 6}, {Start: 1, End: 1}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(report.Files) != 1 {
+		t.Fatalf("Expected 1 file result, got %d", len(report.Files))
+	}
+	f := report.Files[0]
+	if f.BeforeHash == f.AfterHash {
+		t.Errorf("Expected file hash to change")
+	}
+	if len(f.Ranges) != 2 {
+		t.Fatalf("Expected 2 range results, got %d", len(f.Ranges))
+	}
+	if f.Ranges[0].Range.Start != 1 || f.Ranges[0].Status != formatter.StatusFormatted {
+		t.Errorf("Expected line 1 to be formatted, got %+v", f.Ranges[0])
+	}
+	if f.Ranges[1].Range.Start != 5 || f.Ranges[1].Status != formatter.StatusUnchanged {
+		t.Errorf("Expected lines 5-6 to be unchanged, got %+v", f.Ranges[1])
+	}
+}
+
+func TestFormatFilesByLineRangesReportDryRun(t *testing.T) {
+	testutil.InstallFakeTool(t, "ruff", `echo "-x=1"; echo "+x = 1"; echo "1 file would be reformatted" >&2; exit 1`)
+
+	r := New(t.TempDir(), true, false)
+	report, err := r.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	f := report.Files[0]
+	if f.Status() != formatter.StatusWouldReformat {
+		t.Errorf("Expected would-reformat status, got %s", f.Status())
+	}
+	if f.Diff != "-x=1\n+x = 1\n" {
+		t.Errorf("Expected diff from stdout, got %q", f.Diff)
+	}
+	if !strings.Contains(f.Ranges[0].Stderr, "would be reformatted") {
+		t.Errorf("Expected stderr to be captured, got %q", f.Ranges[0].Stderr)
+	}
+}
+
+func TestFormatFilesByLineRangesReportFailure(t *testing.T) {
+	testutil.InstallFakeTool(t, "ruff", `echo "error: Failed to parse" >&2; exit 2`)
+
+	r := New(t.TempDir(), false, false)
+	report, err := r.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}, {Start: 10, End: 12}}},
+		{FilePath: "other.py", LineRanges: []git.LineRange{{Start: 3, End: 3}}},
+	})
+	if err == nil {
+		t.Fatalf("Expected error when ruff fails, got nil")
+	}
+
+	// The failing range stops the file, but other files are still processed
+	if len(report.Files) != 2 {
+		t.Fatalf("Expected 2 file results, got %d", len(report.Files))
+	}
+	if len(report.Files[0].Ranges) != 1 || report.Files[0].Ranges[0].Status != formatter.StatusFailed {
+		t.Errorf("Expected a single failed range for main.py, got %+v", report.Files[0].Ranges)
+	}
+	if report.Files[1].Status() != formatter.StatusFailed {
+		t.Errorf("Expected other.py to fail as well, got %s", report.Files[1].Status())
+	}
+}
+
+func TestFormatFilesByLineRangesParallel(t *testing.T) {
+	repoRoot := t.TempDir()
+	var fileChanges []git.FileChanges
+	for _, name := range []string{"e.py", "d.py", "c.py", "b.py", "a.py"} {
+		if err := os.WriteFile(filepath.Join(repoRoot, name), []byte("x=1\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		fileChanges = append(fileChanges, git.FileChanges{FilePath: name, LineRanges: []git.LineRange{{Start: 1, End: 1}}})
+	}
+
+	// The fake ruff formats whichever file it is given
+	testutil.InstallFakeTool(t, "ruff", `for a in "$@"; do f="$a"; done; echo "x = 1" > "$f"`)
+
+	r := New(repoRoot, false, false)
+	r.SetJobs(3)
+	report, err := r.FormatFilesByLineRanges(fileChanges)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Files are reported in the order they were given, not the order they finished
+	for i, fc := range fileChanges {
+		if report.Files[i].FilePath != fc.FilePath {
+			t.Errorf("Result %d: expected %s, got %s", i, fc.FilePath, report.Files[i].FilePath)
+		}
+		if report.Files[i].Status() != formatter.StatusFormatted {
+			t.Errorf("Expected %s to be formatted, got %s", fc.FilePath, report.Files[i].Status())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package ruff
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/horiagug/ruff-format-changes/internal/diff"
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+)
+
+// diffContext is the number of context lines in dry-run diffs of in-memory formatting
+const diffContext = 3
+
+// formatFileInMemory reads a file once, pipes it through ruff format --stdin-filename
+// for each range bottom-up and writes the result back atomically in a single write.
+// ruff accepts one --range per invocation, so each range still costs a process, but
+// the file is never rewritten in between. If any range fails the file is left untouched.
+func (r *Ruff) formatFileInMemory(fc git.FileChanges, log io.Writer) formatter.FileResult {
+	absPath := filepath.Join(r.repoRoot, fc.FilePath)
+	result := formatter.FileResult{FilePath: fc.FilePath}
+	started := time.Now()
+
+	original, err := os.ReadFile(absPath)
+	if err != nil {
+		for _, lr := range fc.LineRanges {
+			result.Ranges = append(result.Ranges, formatter.RangeResult{
+				Range:  lr,
+				Status: formatter.StatusFailed,
+				Error:  "failed to read file: " + err.Error(),
+			})
+		}
+		result.Duration = time.Since(started)
+		return result
+	}
+	result.BeforeHash = formatter.HashContent(original)
+
+	sortedRanges := make([]git.LineRange, len(fc.LineRanges))
+	copy(sortedRanges, fc.LineRanges)
+	sort.Slice(sortedRanges, func(i, j int) bool {
+		return sortedRanges[i].Start > sortedRanges[j].Start
+	})
+
+	changedStatus := formatter.StatusFormatted
+	if r.dryRun {
+		changedStatus = formatter.StatusWouldReformat
+	}
+
+	content := original
+	failed := false
+	for _, lineRange := range sortedRanges {
+		rangeStarted := time.Now()
+		formatted, stderr, err := r.formatContentWithRange(fc.FilePath, content, lineRange, log)
+
+		rangeResult := formatter.RangeResult{
+			Range:    lineRange,
+			Stderr:   stderr,
+			Duration: time.Since(rangeStarted),
+		}
+		switch {
+		case err != nil:
+			rangeResult.Status = formatter.StatusFailed
+			rangeResult.Error = err.Error()
+			failed = true
+		case !bytes.Equal(formatted, content):
+			rangeResult.Status = changedStatus
+			content = formatted
+		default:
+			rangeResult.Status = formatter.StatusUnchanged
+		}
+
+		result.Ranges = append(result.Ranges, rangeResult)
+		if failed {
+			break
+		}
+	}
+
+	sort.Slice(result.Ranges, func(i, j int) bool {
+		return result.Ranges[i].Range.Start < result.Ranges[j].Range.Start
+	})
+
+	switch {
+	case failed:
+		// Nothing is written, so ranges formatted before the failure stay unchanged on disk
+		for i := range result.Ranges {
+			if result.Ranges[i].Status == formatter.StatusFormatted {
+				result.Ranges[i].Status = formatter.StatusUnchanged
+			}
+		}
+		content = original
+	case r.dryRun:
+		result.Diff = diff.Unified(filepath.ToSlash(fc.FilePath), string(original), string(content), diffContext)
+		content = original
+	case !bytes.Equal(content, original):
+		if err := formatter.WriteFileAtomic(absPath, content); err != nil {
+			for i := range result.Ranges {
+				result.Ranges[i].Status = formatter.StatusFailed
+				result.Ranges[i].Error = err.Error()
+			}
+			content = original
+		}
+	}
+
+	result.AfterHash = formatter.HashContent(content)
+	result.Duration = time.Since(started)
+	return result
+}
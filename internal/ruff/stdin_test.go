@@ -0,0 +1,134 @@
+package ruff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/horiagug/ruff-format-changes/internal/formatter"
+	"github.com/horiagug/ruff-format-changes/internal/git"
+	"github.com/horiagug/ruff-format-changes/internal/testutil"
+)
+
+// Tests for in-memory formatting of files on disk
+
+// stdinFakeRuff reads stdin and adds spaces around "=" on the first line of the
+// range it was given, logging each invocation to calls
+const stdinFakeRuff = `for a in "$@"; do [ "$prev" = "--range" ] && r="$a"; prev="$a"; done
+echo "$*" >> "$CALLS"
+line=${r%%-*}
+case "$r" in 1-*) [ -n "$FAIL_FIRST" ] && { echo "error: Failed to parse" >&2; exit 2; };; esac
+sed "${line}s/=/ = /"`
+
+func setupStdinFile(t *testing.T) (string, string) {
+	t.Helper()
+	repoRoot := t.TempDir()
+	pyFile := filepath.Join(repoRoot, "main.py")
+	if err := os.WriteFile(pyFile, []byte("a=1\nb=2\nc=3\n"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	t.Setenv("CALLS", filepath.Join(t.TempDir(), "calls"))
+	testutil.InstallFakeTool(t, "ruff", stdinFakeRuff)
+	return repoRoot, pyFile
+}
+
+func TestFormatFilesByLineRangesStdin(t *testing.T) {
+	repoRoot, pyFile := setupStdinFile(t)
+
+	r := New(repoRoot, false, false)
+	r.SetStdin(true)
+	report, err := r.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}, {Start: 2, End: 2}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	content, _ := os.ReadFile(pyFile)
+	if string(content) != "a = 1\nb = 2\nc=3\n" {
+		t.Errorf("Unexpected content %q", content)
+	}
+	if info, _ := os.Stat(pyFile); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected permissions to be kept, got %v", info.Mode().Perm())
+	}
+
+	// Every invocation reads stdin instead of rewriting the file
+	calls, _ := os.ReadFile(os.Getenv("CALLS"))
+	lines := strings.Split(strings.TrimSpace(string(calls)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 ruff invocations, got %q", calls)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "--stdin-filename main.py") || !strings.HasSuffix(line, " -") {
+			t.Errorf("Expected ruff to read stdin, got %q", line)
+		}
+	}
+
+	file := report.Files[0]
+	if file.Ranges[0].Status != formatter.StatusFormatted || file.Ranges[1].Status != formatter.StatusFormatted {
+		t.Errorf("Expected both ranges to be formatted, got %+v", file.Ranges)
+	}
+	if file.AfterHash != formatter.HashFile(pyFile) || file.AfterHash == file.BeforeHash {
+		t.Errorf("Expected hashes to reflect the write, got %s -> %s", file.BeforeHash, file.AfterHash)
+	}
+}
+
+func TestFormatFilesByLineRangesStdinFailureLeavesFile(t *testing.T) {
+	repoRoot, pyFile := setupStdinFile(t)
+	t.Setenv("FAIL_FIRST", "1")
+
+	r := New(repoRoot, false, false)
+	r.SetStdin(true)
+	report, err := r.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 1, End: 1}, {Start: 3, End: 3}}},
+	})
+	if err == nil {
+		t.Fatalf("Expected error from failing ruff, got nil")
+	}
+
+	// Line 3 was formatted in memory before line 1 failed, but nothing was written
+	content, _ := os.ReadFile(pyFile)
+	if string(content) != "a=1\nb=2\nc=3\n" {
+		t.Errorf("Expected file to be untouched, got %q", content)
+	}
+
+	ranges := report.Files[0].Ranges
+	if ranges[0].Status != formatter.StatusFailed || !strings.Contains(ranges[0].Error, "Failed to parse") {
+		t.Errorf("Expected first range to fail with ruff's error, got %+v", ranges[0])
+	}
+	if ranges[1].Status != formatter.StatusUnchanged {
+		t.Errorf("Expected last range to be unchanged, got %+v", ranges[1])
+	}
+
+	entries, _ := os.ReadDir(repoRoot)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files, got %v", entries)
+	}
+}
+
+func TestFormatFilesByLineRangesStdinDryRun(t *testing.T) {
+	repoRoot, pyFile := setupStdinFile(t)
+
+	r := New(repoRoot, true, false)
+	r.SetStdin(true)
+	report, err := r.FormatFilesByLineRanges([]git.FileChanges{
+		{FilePath: "main.py", LineRanges: []git.LineRange{{Start: 2, End: 2}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	content, _ := os.ReadFile(pyFile)
+	if string(content) != "a=1\nb=2\nc=3\n" {
+		t.Errorf("Expected file to be untouched, got %q", content)
+	}
+
+	file := report.Files[0]
+	if file.Status() != formatter.StatusWouldReformat {
+		t.Errorf("Expected would-reformat, got %s", file.Status())
+	}
+	if !strings.Contains(file.Diff, "-b=2\n+b = 2\n") {
+		t.Errorf("Expected diff of line 2, got:\n%s", file.Diff)
+	}
+}
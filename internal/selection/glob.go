@@ -0,0 +1,76 @@
+package selection
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether a path relative to the repository root matches a
+// glob. As in .gitignore, a pattern without a slash matches a file or directory
+// name at any depth, while a pattern with a slash is anchored at the root. "**"
+// matches any number of directories, and a pattern matching a directory
+// matches everything below it.
+func matchGlob(pattern, filePath string) bool {
+	pattern = strings.TrimSuffix(strings.TrimPrefix(pattern, "./"), "/")
+	segments := strings.Split(filePath, "/")
+
+	if !strings.Contains(pattern, "/") {
+		for _, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), segments)
+}
+
+// matchSegments matches the segments of a pattern against a prefix of the
+// segments of a path
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// matchAny reports whether a path matches any of the globs
+func matchAny(patterns []string, filePath string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGlob checks that a glob is well formed
+func validateGlob(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("empty glob pattern")
+	}
+	for _, segment := range strings.Split(pattern, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package selection
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/horiagug/ruff-format-changes/internal/toml"
+)
+
+// ruffConfigFiles are the files ruff reads its configuration from, in order of precedence
+var ruffConfigFiles = []string{".ruff.toml", "ruff.toml", "pyproject.toml"}
+
+// readRuffExcludes returns the exclude and extend-exclude globs of the ruff
+// configuration at the repository root. Ruff skips these files when it walks the
+// project, but not when they are passed to it explicitly, as this tool does.
+func readRuffExcludes(repoRoot string) ([]string, error) {
+	for _, name := range ruffConfigFiles {
+		data, err := os.ReadFile(filepath.Join(repoRoot, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		doc, err := toml.Parse(data)
+		if err != nil {
+			// Don't fail on a part of pyproject.toml that isn't ruff's
+			if name == "pyproject.toml" && !toml.MentionsTable(data, "tool", "ruff") {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring %s, which has no [tool.ruff] table and could not be parsed: %v\n", name, err)
+				continue
+			}
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+
+		settings := doc
+		if name == "pyproject.toml" {
+			// A pyproject.toml without a [tool.ruff] table is not a ruff configuration
+			if settings = toml.Table(doc, "tool", "ruff"); settings == nil {
+				continue
+			}
+		}
+
+		var excludes []string
+		for _, key := range []string{"exclude", "extend-exclude"} {
+			value, ok := settings[key]
+			if !ok {
+				continue
+			}
+			patterns, ok := toml.Strings(value)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s in %s: expected a list of strings", key, name)
+			}
+			excludes = append(excludes, patterns...)
+		}
+		return excludes, nil
+	}
+
+	return nil, nil
+}
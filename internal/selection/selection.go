@@ -0,0 +1,126 @@
+// Package selection decides which changed files are formatted, based on their
+// extension, include and exclude globs, a python shebang and ruff's own excludes.
+package selection
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultExtensions are the extensions of the files selected by default
+var DefaultExtensions = []string{"py", "pyi", "pyw", "ipynb"}
+
+// shebangPattern matches the first line of a Python script, e.g. "#!/usr/bin/env python3"
+var shebangPattern = regexp.MustCompile(`^#!.*\bpython[0-9.]*\b`)
+
+// Options configures which files are selected
+type Options struct {
+	// Extensions are the selected file extensions, with or without a leading dot
+	Extensions []string
+	// Include restricts the selection to files matching at least one glob
+	Include []string
+	// Exclude drops files matching any glob
+	Exclude []string
+	// NoShebang stops selecting files without an extension whose first line is
+	// a python shebang
+	NoShebang bool
+}
+
+// Selector selects files by path, relative to the repository root
+type Selector struct {
+	repoRoot     string
+	extensions   map[string]bool
+	include      []string
+	exclude      []string
+	ruffExcludes []string
+	shebang      bool
+}
+
+// New creates a selector for the repository at repoRoot. The exclude and
+// extend-exclude settings of ruff's configuration at the repository root are
+// applied in addition to opts.Exclude.
+func New(repoRoot string, opts Options) (*Selector, error) {
+	extensions := opts.Extensions
+	if len(extensions) == 0 {
+		extensions = DefaultExtensions
+	}
+
+	s := &Selector{
+		repoRoot:   repoRoot,
+		extensions: make(map[string]bool, len(extensions)),
+		include:    opts.Include,
+		exclude:    opts.Exclude,
+		shebang:    !opts.NoShebang,
+	}
+	for _, ext := range extensions {
+		s.extensions[strings.TrimPrefix(strings.TrimSpace(ext), ".")] = true
+	}
+
+	for _, pattern := range append(append([]string{}, opts.Include...), opts.Exclude...) {
+		if err := validateGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	ruffExcludes, err := readRuffExcludes(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range ruffExcludes {
+		if err := validateGlob(pattern); err != nil {
+			return nil, fmt.Errorf("invalid exclude in ruff configuration: %w", err)
+		}
+	}
+	s.ruffExcludes = ruffExcludes
+
+	return s, nil
+}
+
+// RuffExcludes returns the glob patterns ruff's configuration excludes
+func (s *Selector) RuffExcludes() []string {
+	return s.ruffExcludes
+}
+
+// Match reports whether the file at path, relative to the repository root, is selected
+func (s *Selector) Match(filePath string) bool {
+	filePath = filepath.ToSlash(filePath)
+
+	if matchAny(s.exclude, filePath) || matchAny(s.ruffExcludes, filePath) {
+		return false
+	}
+	if len(s.include) > 0 && !matchAny(s.include, filePath) {
+		return false
+	}
+
+	ext := path.Ext(path.Base(filePath))
+	if ext != "" {
+		return s.extensions[ext[1:]]
+	}
+	return s.shebang && hasPythonShebang(filepath.Join(s.repoRoot, filePath))
+}
+
+// hasPythonShebang reports whether the first line of a file is a python shebang
+func hasPythonShebang(filePath string) bool {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head := make([]byte, 256)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	head = head[:n]
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	return shebangPattern.Match(head)
+}
@@ -0,0 +1,202 @@
+package selection
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"migrations", "migrations/0001_initial.py", true},
+		{"migrations", "app/migrations/0001_initial.py", true},
+		{"migrations/**", "migrations/0001_initial.py", true},
+		{"migrations/**", "app/migrations/0001_initial.py", false},
+		{"**/migrations/**", "app/migrations/0001_initial.py", true},
+		{"vendor/", "vendor/lib/six.py", true},
+		{"./vendor", "vendor/six.py", true},
+		{"*_pb2.py", "pkg/api/service_pb2.py", true},
+		{"*_pb2.py", "pkg/api/service.py", false},
+		{"src/*.py", "src/main.py", true},
+		{"src/*.py", "src/pkg/main.py", false},
+		{"src/**/*.py", "src/pkg/main.py", true},
+		{"src/**/*.py", "src/main.py", true},
+		{"/src", "src/main.py", true},
+		{"tests", "src/tests.py", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.path); got != tt.expected {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestValidateGlob(t *testing.T) {
+	if err := validateGlob("src/[a-"); err == nil {
+		t.Errorf("Expected error for malformed glob, got nil")
+	}
+	if err := validateGlob(" "); err == nil {
+		t.Errorf("Expected error for empty glob, got nil")
+	}
+	if err := validateGlob("src/**/*.py"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	repoRoot := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(repoRoot, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	return repoRoot
+}
+
+func TestSelectorMatch(t *testing.T) {
+	repoRoot := writeFiles(t, map[string]string{
+		"bin/manage": "#!/usr/bin/env python3\nimport sys\n",
+		"bin/deploy": "#!/bin/sh\necho deploy\n",
+		"bin/old":    "#!/usr/local/bin/python2.7 -u\n",
+		"bin/empty":  "",
+		"Makefile":   "all:\n",
+		"pyproject.toml": `[project]
+name = "example"
+
+[tool.ruff]
+extend-exclude = ["migrations", "third_party/**"]
+`,
+	})
+
+	selector, err := New(repoRoot, Options{Exclude: []string{"*_pb2.py"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := map[string]bool{
+		"main.py":                     true,
+		"types.pyi":                   true,
+		"gui.pyw":                     true,
+		"analysis.ipynb":              true,
+		"setup.cfg":                   false,
+		"bin/manage":                  true,
+		"bin/old":                     true,
+		"bin/deploy":                  false,
+		"bin/empty":                   false,
+		"bin/missing":                 false,
+		"Makefile":                    false,
+		"app/migrations/0001_init.py": false,
+		"third_party/six.py":          false,
+		"api/service_pb2.py":          false,
+	}
+	for path, expected := range tests {
+		if got := selector.Match(path); got != expected {
+			t.Errorf("Match(%q) = %v, want %v", path, got, expected)
+		}
+	}
+}
+
+func TestSelectorOptions(t *testing.T) {
+	repoRoot := writeFiles(t, map[string]string{
+		"bin/manage": "#!/usr/bin/env python3\n",
+	})
+
+	selector, err := New(repoRoot, Options{
+		Extensions: []string{".py"},
+		Include:    []string{"src", "bin"},
+		NoShebang:  true,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := map[string]bool{
+		"src/main.py":   true,
+		"bin/tool.py":   true,
+		"tests/test.py": false,
+		"src/types.pyi": false,
+		"bin/manage":    false,
+	}
+	for path, expected := range tests {
+		if got := selector.Match(path); got != expected {
+			t.Errorf("Match(%q) = %v, want %v", path, got, expected)
+		}
+	}
+}
+
+func TestNewInvalidGlob(t *testing.T) {
+	if _, err := New(t.TempDir(), Options{Include: []string{"[a-"}}); err == nil {
+		t.Errorf("Expected error for malformed include, got nil")
+	}
+}
+
+func TestReadRuffExcludes(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+		wantErr  string
+	}{
+		{
+			name:  "no configuration",
+			files: map[string]string{},
+		},
+		{
+			name:  "pyproject without ruff",
+			files: map[string]string{"pyproject.toml": "[project]\nname = \"example\"\n"},
+		},
+		{
+			name:     "ruff.toml",
+			files:    map[string]string{"ruff.toml": "exclude = [\"build\"]\nextend-exclude = [\"vendor\"]\n"},
+			expected: []string{"build", "vendor"},
+		},
+		{
+			name: ".ruff.toml takes precedence",
+			files: map[string]string{
+				".ruff.toml":     "extend-exclude = [\"generated\"]\n",
+				"pyproject.toml": "[tool.ruff]\nextend-exclude = [\"vendor\"]\n",
+			},
+			expected: []string{"generated"},
+		},
+		{
+			name:    "invalid type",
+			files:   map[string]string{"ruff.toml": "extend-exclude = 1\n"},
+			wantErr: "invalid extend-exclude in ruff.toml",
+		},
+		{
+			name:    "invalid TOML",
+			files:   map[string]string{"ruff.toml": "extend-exclude = [\n"},
+			wantErr: "failed to parse ruff.toml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			excludes, err := readRuffExcludes(writeFiles(t, tt.files))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(excludes, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, excludes)
+			}
+		})
+	}
+}
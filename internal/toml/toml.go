@@ -0,0 +1,88 @@
+// Package toml reads project configuration such as pyproject.toml and
+// ruff.toml, decoding it with github.com/BurntSushi/toml.
+package toml
+
+import (
+	"bytes"
+	"strings"
+
+	tomllib "github.com/BurntSushi/toml"
+)
+
+// Parse decodes a TOML document into nested tables. Tables are map[string]any and
+// values are string, int64, float64, bool, time.Time, []any, tables or, for
+// arrays of tables, []map[string]any.
+func Parse(data []byte) (map[string]any, error) {
+	// Editors on Windows may start the file with a byte order mark
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	doc := map[string]any{}
+	if _, err := tomllib.Decode(string(data), &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Table returns the table at the given key path, e.g. Table(doc, "tool", "ruff"),
+// or nil if there is none
+func Table(doc map[string]any, path ...string) map[string]any {
+	table := doc
+	for _, key := range path {
+		next, ok := table[key].(map[string]any)
+		if !ok {
+			return nil
+		}
+		table = next
+	}
+	return table
+}
+
+// MentionsTable reports whether a document, which may not parse, declares the
+// table at the given key path or one nested in it, with a [table] or [[array]]
+// header or a dotted key. It lets callers tell a broken section they need from
+// one they would ignore.
+func MentionsTable(data []byte, path ...string) bool {
+	name := strings.Join(path, ".")
+	unquote := strings.NewReplacer(" ", "", "\t", "", `"`, "", "'", "")
+
+	header := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		var key string
+		switch i := strings.IndexByte(line, '='); {
+		case strings.HasPrefix(line, "["):
+			header = unquote.Replace(strings.Trim(line[:strings.IndexByte(line+"]", ']')], "["))
+			key = header
+		case i > 0 && header != "":
+			key = header + "." + unquote.Replace(line[:i])
+		case i > 0:
+			key = unquote.Replace(line[:i])
+		default:
+			continue
+		}
+
+		if key == name || strings.HasPrefix(key, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings converts an array of strings, or a single string, to a string slice
+func Strings(value any) ([]string, bool) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, true
+	case []any:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			strs = append(strs, s)
+		}
+		return strs, true
+	}
+	return nil, false
+}
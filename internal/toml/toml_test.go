@@ -0,0 +1,157 @@
+package toml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePyproject(t *testing.T) {
+	doc, err := Parse([]byte(`# Project metadata
+[project]
+name = "example"
+version = '1.0'
+dependencies = [
+    "requests>=2",  # HTTP
+    "click",
+]
+
+[tool.ruff]
+line-length = 100
+target-version = "py311"
+extend-exclude = ["migrations", "vendor/**"]
+
+[tool.ruff.lint]
+select = ["E", "F"]
+per-file-ignores = { "__init__.py" = ["F401"] }
+
+[[tool.poetry.source]]
+name = "internal"
+url = "https://example.com/simple"
+
+[[tool.poetry.source]]
+name = "mirror"
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	ruff := Table(doc, "tool", "ruff")
+	if ruff == nil {
+		t.Fatalf("Expected [tool.ruff] table")
+	}
+	if ruff["line-length"] != int64(100) || ruff["target-version"] != "py311" {
+		t.Errorf("Unexpected [tool.ruff] values: %v", ruff)
+	}
+
+	exclude, ok := Strings(ruff["extend-exclude"])
+	if !ok || !reflect.DeepEqual(exclude, []string{"migrations", "vendor/**"}) {
+		t.Errorf("Expected extend-exclude to be read, got %v", ruff["extend-exclude"])
+	}
+
+	ignores := Table(doc, "tool", "ruff", "lint", "per-file-ignores")
+	if got, _ := Strings(ignores["__init__.py"]); !reflect.DeepEqual(got, []string{"F401"}) {
+		t.Errorf("Expected inline table with quoted key, got %v", ignores)
+	}
+
+	deps, _ := Strings(Table(doc, "project")["dependencies"])
+	if !reflect.DeepEqual(deps, []string{"requests>=2", "click"}) {
+		t.Errorf("Expected multi-line array with comments, got %v", deps)
+	}
+
+	sources, ok := Table(doc, "tool", "poetry")["source"].([]map[string]any)
+	if !ok || len(sources) != 2 {
+		t.Fatalf("Expected array of two tables, got %v", Table(doc, "tool", "poetry")["source"])
+	}
+	if sources[1]["name"] != "mirror" {
+		t.Errorf("Expected second table of the array to be mirror, got %v", sources[1])
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := Parse([]byte(`int = +1_000
+hex = 0xff
+float = 6.5e-1
+bool = false
+date = 1979-05-27T07:32:00Z
+escaped = "tab\there \"quoted\" \u00e9"
+literal = 'C:\path'
+dotted.key = "nested"
+multi = """
+first \
+    second
+"""
+multiliteral = '''
+raw \n'''
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := map[string]any{
+		"int":          int64(1000),
+		"hex":          int64(255),
+		"float":        0.65,
+		"bool":         false,
+		"date":         time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC),
+		"escaped":      "tab\there \"quoted\" é",
+		"literal":      `C:\path`,
+		"dotted":       map[string]any{"key": "nested"},
+		"multi":        "first second\n",
+		"multiliteral": `raw \n`,
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("Expected %v, got %v", expected, doc)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"a = 1\nb = \"unterminated\n":   "line 2",
+		"a = 1\na = 2\n":                "'a' has already been defined",
+		"[t]\na = 1\n[u]\n[t]\nb = 2\n": "line 4: Key 't' has already been defined",
+		"mode = 0755\n":                 "cannot have leading zeroes",
+		"a = [1, 2\n":                   "array terminator",
+		"[table\n":                      "to end table name",
+		"a = 1 b = 2\n":                 "to end with a newline",
+		"a = nope\n":                    "expected value",
+	}
+	for input, expected := range tests {
+		_, err := Parse([]byte(input))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Parse(%q): expected error containing %q, got %v", input, expected, err)
+		}
+	}
+}
+
+func TestParseByteOrderMark(t *testing.T) {
+	doc, err := Parse([]byte("\xef\xbb\xbf[tool.ruff]\nline-length = 100\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := Table(doc, "tool", "ruff")["line-length"]; got != int64(100) {
+		t.Errorf("Expected line-length 100, got %v", got)
+	}
+}
+
+func TestMentionsTable(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected bool
+	}{
+		{doc: "[tool.ruff]\nline-length = 1\n", expected: true},
+		{doc: "[ tool . \"ruff\" . lint ]\n", expected: true},
+		{doc: "[[tool.ruff.x]]\n", expected: true},
+		{doc: "tool.ruff.line-length = 1\n", expected: true},
+		{doc: "[tool]\nruff.exclude = [\n", expected: true},
+		{doc: "[tool.ruff-format-changes]\nbase = \"main\"\n", expected: false},
+		{doc: "[project]\nname = broken\n# [tool.ruff]\n", expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := MentionsTable([]byte(tt.doc), "tool", "ruff"); got != tt.expected {
+			t.Errorf("MentionsTable(%q): expected %v, got %v", tt.doc, tt.expected, got)
+		}
+	}
+}
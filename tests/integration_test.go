@@ -448,7 +448,7 @@ def function_two():
 
 	// Try to format with ruff (if available)
 	ruffClient := ruff.New(tmpDir, false, false)
-	err = ruffClient.FormatFilesByLineRanges(changedLines)
+	_, err = ruffClient.FormatFilesByLineRanges(changedLines)
 	if err != nil {
 		t.Logf("Ruff formatting error (may be expected if ruff not installed): %v", err)
 		return